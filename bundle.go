@@ -0,0 +1,218 @@
+package jsonvalidate
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Bundle returns the schema in r with the given URI as a single, self-contained
+// schema, which can be passed to NewRegistry on its own.
+//
+// Every schema which the root schema refers to, directly or indirectly, and
+// which is outside of the root schema, is copied into the definitions of the
+// bundle, and refs to it are rewritten to refer to that definition. Such a
+// definition takes the name of the definition it was copied from, or for a
+// whole schema, the last segment of its URI's path without any extension. If
+// that name is already taken, a number is appended to it. The root schema's
+// own definitions keep their names.
+func (r Registry) Bundle(uri url.URL) (SchemaStruct, error) {
+	root, ok := r.Schemas[uri]
+	if !ok {
+		return SchemaStruct{}, fmt.Errorf("no schema with uri: %s", uri.String())
+	}
+
+	b := bundler{root: uri, seen: map[bundleRef]*Schema{}}
+	b.walk(root, true)
+
+	refs := make([]bundleRef, 0, len(b.seen))
+	for ref := range b.seen {
+		refs = append(refs, ref)
+	}
+
+	// Sort, so that the same registry always produces the same names.
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].uri.String() != refs[j].uri.String() {
+			return refs[i].uri.String() < refs[j].uri.String()
+		}
+
+		return refs[i].definition < refs[j].definition
+	})
+
+	b.names = map[bundleRef]string{}
+	taken := map[string]bool{}
+	for name := range root.Definitions {
+		taken[name] = true
+	}
+
+	for _, ref := range refs {
+		name := ref.name()
+		for i := 2; taken[name]; i++ {
+			name = fmt.Sprintf("%s_%d", ref.name(), i)
+		}
+
+		b.names[ref] = name
+		taken[name] = true
+	}
+
+	out := b.schemaStruct(root)
+
+	if root.ID.String() != "" {
+		id := root.ID.String()
+		out.ID = &id
+	}
+
+	if len(root.Definitions)+len(refs) > 0 {
+		definitions := make(map[string]SchemaStruct, len(root.Definitions)+len(refs))
+		for name, schema := range root.Definitions {
+			definitions[name] = b.schemaStruct(schema)
+		}
+
+		for _, ref := range refs {
+			definitions[b.names[ref]] = b.schemaStruct(b.seen[ref])
+		}
+
+		out.Definitions = &definitions
+	}
+
+	return out, nil
+}
+
+// bundleRef is a schema which can be referred to: either a root schema, with an
+// empty definition, or one of its definitions.
+type bundleRef struct {
+	uri        url.URL
+	definition string
+}
+
+// name is the name of the definition to use for r in a bundle, before any
+// collisions have been accounted for.
+func (r bundleRef) name() string {
+	if r.definition != "" {
+		return r.definition
+	}
+
+	name := path.Base(r.uri.Path)
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if name == "" || name == "." || name == "/" {
+		return "schema"
+	}
+
+	return name
+}
+
+// bundler collects, and then converts, the schemas which make up a bundle.
+type bundler struct {
+	root  url.URL               // the URI of the schema being bundled
+	seen  map[bundleRef]*Schema // schemas outside root which are referred to
+	names map[bundleRef]string  // the definition name of each of seen
+}
+
+// refOf returns what schema, which must be a ref, refers to.
+func refOf(schema *Schema) bundleRef {
+	uri := schema.Base.ResolveReference(schema.Ref)
+	definition := uri.Fragment
+	uri.Fragment = ""
+
+	return bundleRef{uri: *uri, definition: definition}
+}
+
+// walk adds to b.seen every schema outside of b.root which schema refers to,
+// directly or indirectly. Definitions are only walked into if withDefinitions
+// is true, because the definitions of schemas other than b.root are only
+// included in the bundle if they are referred to.
+func (b *bundler) walk(schema *Schema, withDefinitions bool) {
+	if schema.Kind == SchemaKindRef {
+		ref := refOf(schema)
+		if _, ok := b.seen[ref]; !ok && ref.uri != b.root {
+			b.seen[ref] = schema.RefSchema
+			b.walk(schema.RefSchema, false)
+		}
+	}
+
+	// subSchemas puts the definitions of schema first.
+	subSchemas := subSchemas(schema)
+	if !withDefinitions {
+		subSchemas = subSchemas[len(schema.Definitions):]
+	}
+
+	for _, subSchema := range subSchemas {
+		b.walk(subSchema, false)
+	}
+}
+
+// schemaStruct converts schema back into a SchemaStruct, rewriting its refs to
+// be within the bundle. The "id" and "definitions" of schema are left out; it
+// is up to the caller to add them back to the root of the bundle.
+func (b *bundler) schemaStruct(schema *Schema) SchemaStruct {
+	out := SchemaStruct{Extra: schema.Extra}
+
+	if schema.Nullable {
+		nullable := true
+		out.Nullable = &nullable
+	}
+
+	switch schema.Kind {
+	case SchemaKindRef:
+		ref := refOf(schema)
+
+		var s string
+		switch {
+		case ref.uri != b.root:
+			s = "#" + b.names[ref]
+		case ref.definition != "":
+			s = "#" + ref.definition
+		default:
+			s = b.root.String()
+		}
+
+		out.Ref = &s
+	case SchemaKindType:
+		t := schema.Type.String()
+		out.Type = &t
+	case SchemaKindEnum:
+		enum := make([]string, 0, len(schema.Enum))
+		for v := range schema.Enum {
+			enum = append(enum, v)
+		}
+
+		sort.Strings(enum)
+		out.Enum = &enum
+	case SchemaKindElements:
+		elements := b.schemaStruct(schema.Elements)
+		out.Elements = &elements
+	case SchemaKindProperties:
+		if schema.Properties != nil {
+			properties := b.schemaStructMap(schema.Properties)
+			out.Properties = &properties
+		}
+
+		if schema.OptionalProperties != nil {
+			optionalProperties := b.schemaStructMap(schema.OptionalProperties)
+			out.OptionalProperties = &optionalProperties
+		}
+
+		out.AdditionalProperties = schema.AdditionalProperties
+	case SchemaKindValues:
+		values := b.schemaStruct(schema.Values)
+		out.Values = &values
+	case SchemaKindDiscriminator:
+		out.Discriminator = &SchemaStructDiscriminator{
+			PropertyName: schema.DiscriminatorPropertyName,
+			Mapping:      b.schemaStructMap(schema.DiscriminatorMapping),
+		}
+	}
+
+	return out
+}
+
+func (b *bundler) schemaStructMap(schemas map[string]*Schema) map[string]SchemaStruct {
+	out := make(map[string]SchemaStruct, len(schemas))
+	for k, v := range schemas {
+		out[k] = b.schemaStruct(v)
+	}
+
+	return out
+}
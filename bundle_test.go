@@ -0,0 +1,130 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBundle(t *testing.T) {
+	var schemas []SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{
+			"id": "http://example.com/root.json",
+			"title": "Root",
+			"definitions": {
+				"name": {"type": "string"},
+				"unused": {"ref": "http://example.com/other.json#unused"}
+			},
+			"properties": {
+				"name": {"ref": "#name"},
+				"user": {"ref": "users/user.json"},
+				"tree": {"ref": "tree.json"}
+			},
+			"optionalProperties": {
+				"kind": {"ref": "users/user.json#kind"}
+			}
+		},
+		{
+			"id": "http://example.com/users/user.json",
+			"definitions": {
+				"name": {"type": "string", "nullable": true},
+				"kind": {"enum": ["b", "a"]},
+				"ignored": {"ref": "http://example.com/ignored.json"}
+			},
+			"properties": {
+				"name": {"ref": "#name"},
+				"kind": {"ref": "#kind"},
+				"root": {"ref": "../root.json#name"}
+			},
+			"additionalProperties": true
+		},
+		{
+			"id": "http://example.com/tree.json",
+			"values": {"ref": "tree.json"}
+		},
+		{
+			"id": "http://example.com/other.json",
+			"definitions": {"unused": {"type": "boolean"}}
+		},
+		{
+			"id": "http://example.com/ignored.json"
+		}
+	]`), &schemas))
+
+	registry, err := NewRegistry(schemas)
+	assert.NoError(t, err)
+
+	uri, err := url.Parse("http://example.com/root.json")
+	assert.NoError(t, err)
+
+	bundle, err := registry.Bundle(*uri)
+	assert.NoError(t, err)
+
+	var expected SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"id": "http://example.com/root.json",
+		"title": "Root",
+		"definitions": {
+			"name": {"type": "string"},
+			"unused": {"ref": "#unused_2"},
+			"unused_2": {"type": "boolean"},
+			"tree": {"values": {"ref": "#tree"}},
+			"user": {
+				"properties": {
+					"name": {"ref": "#name_2"},
+					"kind": {"ref": "#kind"},
+					"root": {"ref": "#name"}
+				},
+				"additionalProperties": true
+			},
+			"name_2": {"type": "string", "nullable": true},
+			"kind": {"enum": ["a", "b"]}
+		},
+		"properties": {
+			"name": {"ref": "#name"},
+			"user": {"ref": "#user"},
+			"tree": {"ref": "#tree"}
+		},
+		"optionalProperties": {
+			"kind": {"ref": "#kind"}
+		}
+	}`), &expected))
+
+	expectedJSON, err := json.Marshal(expected)
+	assert.NoError(t, err)
+
+	bundleJSON, err := json.Marshal(bundle)
+	assert.NoError(t, err)
+
+	assert.Equal(t, string(expectedJSON), string(bundleJSON))
+
+	// The bundle validates instances just like the registry it came from.
+	bundled, err := NewRegistry([]SchemaStruct{bundle})
+	assert.NoError(t, err)
+
+	var instance interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"name": "a",
+		"user": {"name": null, "kind": "c", "root": 3, "extra": true},
+		"tree": {"a": {"b": {}, "c": 1}},
+		"kind": "a"
+	}`), &instance))
+
+	result, err := Validator{Registry: registry}.ValidateURI(*uri, instance)
+	assert.NoError(t, err)
+
+	bundledResult, err := Validator{Registry: bundled}.ValidateURI(*uri, instance)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 3, len(result.Errors))
+	assert.Equal(t, len(result.Errors), len(bundledResult.Errors))
+	for i := range result.Errors {
+		assert.Equal(t, result.Errors[i].InstancePath, bundledResult.Errors[i].InstancePath)
+	}
+
+	_, err = registry.Bundle(url.URL{Path: "missing"})
+	assert.Error(t, err)
+}
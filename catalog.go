@@ -0,0 +1,118 @@
+package jsonvalidate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MessageCatalog turns validation errors into messages in some language.
+//
+// Implementations should describe the error according to the Kind of its
+// Explanation, and must handle errors whose Explanation is nil.
+type MessageCatalog interface {
+	Message(err ValidationError) string
+}
+
+// DefaultCatalog is the catalog used by ValidationError.Message.
+var DefaultCatalog MessageCatalog = EnglishCatalog{}
+
+// EnglishCatalog generates messages in English, such as "expected string, got
+// number 3".
+type EnglishCatalog struct{}
+
+// Message implements MessageCatalog.
+func (EnglishCatalog) Message(err ValidationError) string {
+	if err.Explanation == nil {
+		return fmt.Sprintf("value does not satisfy %#v", err.SchemaPath.String())
+	}
+
+	e := err.Explanation
+	switch e.Kind {
+	case ErrorKindType:
+		return fmt.Sprintf("expected %s, got %s", strings.Join(e.Expected, ", "), describeValue(e.Value))
+	case ErrorKindEnum:
+		return fmt.Sprintf("expected one of %s, got %s", quoteAll(e.Expected), describeValue(e.Value))
+	case ErrorKindNotArray:
+		return fmt.Sprintf("expected array, got %s", describeValue(e.Value))
+	case ErrorKindNotObject:
+		return fmt.Sprintf("expected object, got %s", describeValue(e.Value))
+	case ErrorKindMissingProperty:
+		return fmt.Sprintf("missing required property %q", e.Property)
+	case ErrorKindAdditionalProperty:
+		return fmt.Sprintf("unexpected property %q", e.Property)
+	case ErrorKindMissingDiscriminator:
+		return fmt.Sprintf("missing discriminator property %q", e.Property)
+	case ErrorKindDiscriminatorNotString:
+		return fmt.Sprintf("expected discriminator property %q to be a string, got %s", e.Property, describeValue(e.Value))
+	case ErrorKindBadDiscriminator:
+		return fmt.Sprintf("expected discriminator property %q to be one of %s, got %s", e.Property, quoteAll(e.Expected), describeValue(e.Value))
+	}
+
+	return fmt.Sprintf("value does not satisfy %#v", err.SchemaPath.String())
+}
+
+// Catalogs maps locales, such as "fr" or "pt-BR", to the catalog for that
+// locale.
+type Catalogs map[string]MessageCatalog
+
+// Lookup returns the catalog for locale. If there is no catalog for locale but
+// there is one for its language, such as "pt" for "pt-BR", that catalog is
+// returned. Otherwise, Lookup returns DefaultCatalog.
+func (c Catalogs) Lookup(locale string) MessageCatalog {
+	if catalog, ok := c[locale]; ok {
+		return catalog
+	}
+
+	if i := strings.IndexAny(locale, "-_"); i != -1 {
+		if catalog, ok := c[locale[:i]]; ok {
+			return catalog
+		}
+	}
+
+	return DefaultCatalog
+}
+
+// Messages returns a message for each of the errors in r, in order, using
+// catalog. Call Explain first to get messages which describe each problem.
+func (r ValidationResult) Messages(catalog MessageCatalog) []string {
+	out := make([]string, len(r.Errors))
+	for i, err := range r.Errors {
+		out[i] = err.MessageIn(catalog)
+	}
+
+	return out
+}
+
+// describeValue describes a value as it would appear in JSON: its type and,
+// unless it is an array or object, the value itself.
+func describeValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("boolean %t", value)
+	case string:
+		return fmt.Sprintf("string %q", value)
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	if isNumber(value, false) {
+		return fmt.Sprintf("number %v", value)
+	}
+
+	return fmt.Sprintf("%T", value)
+}
+
+// quoteAll lists values as quoted strings.
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,71 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testCatalog string
+
+func (c testCatalog) Message(err ValidationError) string {
+	if err.Explanation == nil {
+		return string(c) + ": ?"
+	}
+
+	switch err.Explanation.Kind {
+	case ErrorKindType:
+		return string(c) + ": type " + err.Explanation.Expected[0]
+	case ErrorKindMissingProperty:
+		return string(c) + ": missing " + err.Explanation.Property
+	}
+
+	return string(c) + ": other"
+}
+
+func TestCatalogsLookup(t *testing.T) {
+	catalogs := Catalogs{
+		"fr":    testCatalog("fr"),
+		"pt":    testCatalog("pt"),
+		"pt-BR": testCatalog("pt-BR"),
+	}
+
+	assert.Equal(t, testCatalog("fr"), catalogs.Lookup("fr"))
+	assert.Equal(t, testCatalog("fr"), catalogs.Lookup("fr-CA"))
+	assert.Equal(t, testCatalog("pt-BR"), catalogs.Lookup("pt-BR"))
+	assert.Equal(t, testCatalog("pt"), catalogs.Lookup("pt_PT"))
+	assert.Equal(t, DefaultCatalog, catalogs.Lookup("de"))
+	assert.Equal(t, DefaultCatalog, Catalogs(nil).Lookup("en"))
+}
+
+func TestValidationResultMessages(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"a": { "type": "string" },
+			"b": { "type": "string", "errorMessage": "custom" },
+			"c": {}
+		}
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	var instance interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"a":1,"b":1}`), &instance))
+
+	result, err := Validator{Registry: registry}.Validate(instance)
+	assert.NoError(t, err)
+
+	catalog := Catalogs{"fr": testCatalog("fr")}.Lookup("fr-FR")
+	assert.Equal(t, []string{"fr: ?", "custom", "fr: ?"}, result.Messages(catalog))
+
+	result.Explain(registry, instance)
+	assert.Equal(t, []string{"fr: type string", "custom", "fr: missing c"}, result.Messages(catalog))
+	assert.Equal(t, []string{
+		"expected string, got number 1",
+		"custom",
+		`missing required property "c"`,
+	}, result.Messages(DefaultCatalog))
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net/url"
 	"os"
 
 	"github.com/json-validate/json-pointer-go"
@@ -34,6 +36,24 @@ EXAMPLE:
 					validate-json -u http://foo.com/bar defs1.json defs2.json schema.json
 
 		 The order of the arguments in the two examples above does not matter.
+
+		 To include the line and column at which each error occurs, use
+		 --positions or -p. This reads all of STDIN into memory before validating
+		 it:
+
+					validate-json -p schema.json
+
+		 To describe each error, such as "expected string, got number 3", use
+		 --explain or -e. This reads each value from STDIN into memory before
+		 validating it:
+
+					validate-json -e schema.json
+
+		 To combine schema.json, and every schema it relies on, into a single
+		 schema which can be used on its own, use the bundle command. Just like
+		 with validation, -u picks a schema other than the default one:
+
+					validate-json bundle defs1.json defs2.json schema.json
 `
 
 type outputFormat int
@@ -63,6 +83,31 @@ func main() {
 			Usage: "how to format validation errors",
 			Value: "string",
 		},
+		cli.BoolFlag{
+			Name:  "positions, p",
+			Usage: "report the line and column of each error",
+		},
+		cli.BoolFlag{
+			Name:  "explain, e",
+			Usage: "describe each error in the string output format",
+		},
+	}
+
+	app.Commands = []cli.Command{
+		{
+			Name:      "bundle",
+			Usage:     "combine a schema and every schema it refers to into one schema",
+			ArgsUsage: "schemas...",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "schema-uri, u",
+					Usage: "the URI of the schema to bundle",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return bundle(c.Args(), c.String("schema-uri"))
+			},
+		},
 	}
 
 	app.CustomAppHelpTemplate = cli.AppHelpTemplate + exampleMessage
@@ -79,7 +124,7 @@ func main() {
 			return fmt.Errorf("unknown format: %s", c.String("format"))
 		}
 
-		return run(c.Args(), format)
+		return run(c.Args(), c.String("schema-uri"), format, c.Bool("positions"), c.Bool("explain"))
 	}
 
 	err := app.Run(os.Args)
@@ -88,38 +133,111 @@ func main() {
 	}
 }
 
-func run(schemaPaths []string, format outputFormat) error {
-	// parse each of the inputted paths into Schema structs
-	schemas := make([]jsonvalidate.SchemaStruct, len(schemaPaths))
-	for i, schemaPath := range schemaPaths {
-		reader, err := os.Open(schemaPath)
-		if err != nil {
-			return err
-		}
+// load constructs a registry from the given schemas, reporting which file each
+// problem with them is in
+func load(schemaPaths []string) (jsonvalidate.Registry, error) {
+	registry, err := jsonvalidate.LoadFiles(schemaPaths, jsonvalidate.LoadOptions{})
+	if err != nil {
+		if loadErrors, ok := err.(jsonvalidate.LoadErrors); ok {
+			for _, loadErr := range loadErrors {
+				fmt.Fprintln(os.Stderr, loadErr)
+			}
 
-		decoder := json.NewDecoder(reader)
-		err = decoder.Decode(&schemas[i])
-		if err != nil {
-			return err
+			return jsonvalidate.Registry{}, fmt.Errorf("invalid schemas")
 		}
+
+		return jsonvalidate.Registry{}, err
+	}
+
+	return registry, nil
+}
+
+func bundle(schemaPaths []string, schemaURI string) error {
+	registry, err := load(schemaPaths)
+	if err != nil {
+		return err
+	}
+
+	uri, err := url.Parse(schemaURI)
+	if err != nil {
+		return err
 	}
 
+	schema, err := registry.Bundle(*uri)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout) // outputs the bundle to stdout
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(schema)
+}
+
+func run(schemaPaths []string, schemaURI string, format outputFormat, positions, explain bool) error {
 	// construct a new validator from the given schemas
-	registry, err := jsonvalidate.NewRegistry(schemas)
+	registry, err := load(schemaPaths)
+	if err != nil {
+		return err
+	}
+
+	// the schema to validate against, which is the default one if unset
+	uri, err := url.Parse(schemaURI)
 	if err != nil {
 		return err
 	}
 
-	validator := jsonvalidate.Validator{Registry: registry}
+	// report every error, rather than stopping at the library's default limit
+	validator := jsonvalidate.Validator{Registry: registry, MaxErrors: jsonvalidate.Unlimited}
+
+	// validate the next JSON value in stdin. If we're reporting positions or
+	// explaining errors, the value has to be decoded up front; otherwise, it's
+	// validated as it's read.
+	var next func() (jsonvalidate.ValidationResult, error)
+	if positions {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+
+		decoder := jsonvalidate.NewPositionDecoder(data)
+		next = func() (jsonvalidate.ValidationResult, error) {
+			instance, instancePositions, err := decoder.Decode()
+			if err != nil {
+				return jsonvalidate.ValidationResult{}, err
+			}
+
+			result, err := validator.ValidateURI(*uri, instance)
+			result.AddPositions(instancePositions)
+			if explain {
+				result.Explain(registry, instance)
+			}
+
+			return result, err
+		}
+	} else if explain && format == outputFormatString {
+		decoder := json.NewDecoder(os.Stdin) // parses JSON from stdin
+		next = func() (jsonvalidate.ValidationResult, error) {
+			var instance interface{}
+			if err := decoder.Decode(&instance); err != nil {
+				return jsonvalidate.ValidationResult{}, err
+			}
+
+			result, err := validator.ValidateURI(*uri, instance)
+			result.Explain(registry, instance)
+			return result, err
+		}
+	} else {
+		decoder := json.NewDecoder(os.Stdin) // parses JSON from stdin
+		next = func() (jsonvalidate.ValidationResult, error) {
+			return validator.ValidateDecoder(*uri, decoder)
+		}
+	}
 
-	decoder := json.NewDecoder(os.Stdin)  // parses JSON from stdin
 	encoder := json.NewEncoder(os.Stdout) // outputs JSON to stdout (for json output format)
 
 	// i keeps track of which instance we're evaluating
 	for i := 0; true; i++ {
-		// read a JSON value out of stdin
-		var instance interface{}
-		err := decoder.Decode(&instance)
+		result, err := next()
 		if err != nil {
 			if err == io.EOF {
 				return nil
@@ -128,31 +246,32 @@ func run(schemaPaths []string, format outputFormat) error {
 			return err
 		}
 
-		// validate the parsed JSON value
-		result, err := validator.Validate(instance)
-		if err != nil {
-			return err
-		}
-
 		// output the errors
 		for _, vErr := range result.Errors {
 			switch format {
 			case outputFormatString:
+				position := ""
+				if vErr.Position != nil {
+					position = fmt.Sprintf(" (%s)", vErr.Position)
+				}
+
 				fmt.Printf(
-					"%d: error at: %#v (due to %#v) (schema id: %#v)\n",
-					i, vErr.InstancePath.String(), vErr.SchemaPath.String(), vErr.SchemaURI.String(),
+					"%d: error at: %#v%s: %s (due to %#v) (schema id: %#v)\n",
+					i, vErr.InstancePath.String(), position, vErr.Message(), vErr.SchemaPath.String(), vErr.SchemaURI.String(),
 				)
 			case outputFormatJSON:
 				out := struct {
-					Instance     int             `json:"instance"`
-					InstancePath jsonpointer.Ptr `json:"instancePath"`
-					SchemaPath   jsonpointer.Ptr `json:"schemaPath"`
-					SchemaURI    string          `json:"schemaURI"`
+					Instance     int                    `json:"instance"`
+					InstancePath jsonpointer.Ptr        `json:"instancePath"`
+					SchemaPath   jsonpointer.Ptr        `json:"schemaPath"`
+					SchemaURI    string                 `json:"schemaURI"`
+					Position     *jsonvalidate.Position `json:"position,omitempty"`
 				}{
 					i,
 					vErr.InstancePath,
 					vErr.SchemaPath,
 					vErr.SchemaURI.String(),
+					vErr.Position,
 				}
 
 				err := encoder.Encode(out)
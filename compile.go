@@ -0,0 +1,534 @@
+package jsonvalidate
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/json-validate/json-pointer-go"
+)
+
+// Program is a schema which has been compiled ahead of time into a tree of
+// closures.
+//
+// Validating an instance with a Program produces the same results as
+// Validator.ValidateURI, but all of the work of walking the schema, looking up
+// references, and computing schema paths is done once, in Registry.Compile. A
+// Program is safe for concurrent use, and is meant to be constructed once and
+// then reused for many instances.
+type Program struct {
+	// These fields have the same meaning as in Validator.
+	MaxErrors                    int
+	MaxDepth                     int
+	MaxInstanceDepth             int
+	StrictNumbers                bool
+	DisallowAdditionalProperties bool
+
+	root  evalFunc
+	state sync.Pool
+}
+
+// evalFunc is a compiled schema.
+type evalFunc func(s *programState, instance interface{}) error
+
+// programState is the per-call state of a Program. It is pooled so that
+// repeated validations do not allocate anything beyond the errors they report.
+type programState struct {
+	maxErrors          int
+	maxDepth           int
+	maxInstanceDepth   int
+	strictNumbers      bool
+	disallowAdditional bool
+	depth              int
+	instanceTokens     []instanceToken
+	keys               []string // see pushKeys
+	errors             []ValidationError
+}
+
+// instanceToken is an element of an instance path. Array indices are kept as
+// ints, and only formatted when an error is reported.
+type instanceToken struct {
+	property string
+	index    int
+}
+
+// Compile compiles the schema with the given URI into a Program.
+func (r Registry) Compile(uri url.URL) (*Program, error) {
+	schema, ok := r.Schemas[uri]
+	if !ok {
+		return nil, fmt.Errorf("no schema with uri: %s", uri.String())
+	}
+
+	c := compiler{refs: map[*Schema]*evalFunc{}}
+	p := &Program{root: c.compile(schema, &uri, []string{})}
+	p.state.New = func() interface{} {
+		return &programState{}
+	}
+
+	return p, nil
+}
+
+// Validate validates an instance against the compiled schema.
+func (p *Program) Validate(instance interface{}) (ValidationResult, error) {
+	s := p.state.Get().(*programState)
+	defer p.state.Put(s)
+
+	s.maxErrors = limit(p.MaxErrors, DefaultMaxErrors)
+	s.maxDepth = limit(p.MaxDepth, DefaultMaxDepth)
+	s.maxInstanceDepth = limit(p.MaxInstanceDepth, DefaultMaxInstanceDepth)
+	s.strictNumbers = p.StrictNumbers
+	s.disallowAdditional = p.DisallowAdditionalProperties
+	s.depth = 1
+	s.instanceTokens = s.instanceTokens[:0]
+	s.keys = s.keys[:0]
+	s.errors = []ValidationError{}
+
+	if err := p.root(s, instance); err != nil {
+		if err != errMaxErrors {
+			return ValidationResult{}, err
+		}
+	}
+
+	errors := s.errors
+	s.errors = nil
+
+	return ValidationResult{Errors: errors}, nil
+}
+
+// reportError records an error at schemaPath and the current instance path.
+// message is the CustomMessage which the schema configures for the error.
+func (s *programState) reportError(uri *url.URL, schemaPath jsonpointer.Ptr, message string) error {
+	instancePath := make([]string, len(s.instanceTokens))
+	for i, t := range s.instanceTokens {
+		if t.index < 0 {
+			instancePath[i] = t.property
+		} else {
+			instancePath[i] = strconv.Itoa(t.index)
+		}
+	}
+
+	s.errors = append(s.errors, ValidationError{
+		InstancePath:  jsonpointer.Ptr{Tokens: instancePath},
+		SchemaPath:    schemaPath,
+		SchemaURI:     *uri,
+		CustomMessage: message,
+	})
+
+	if len(s.errors) == s.maxErrors {
+		return errMaxErrors
+	}
+
+	return nil
+}
+
+// reportErrorAndPop reports an error, and then pops the last instance token.
+func (s *programState) reportErrorAndPop(uri *url.URL, schemaPath jsonpointer.Ptr, message string) error {
+	if err := s.reportError(uri, schemaPath, message); err != nil {
+		return err
+	}
+
+	s.popInstanceToken()
+	return nil
+}
+
+// checkInstanceDepth is called before evaluating the contents of an array or
+// object, and ensures the array or object is not nested too deeply.
+func (s *programState) checkInstanceDepth() error {
+	if len(s.instanceTokens) == s.maxInstanceDepth {
+		return ErrMaxInstanceDepth
+	}
+
+	return nil
+}
+
+func (s *programState) pushProperty(property string) {
+	s.instanceTokens = append(s.instanceTokens, instanceToken{property: property, index: -1})
+}
+
+func (s *programState) pushIndex(index int) {
+	s.instanceTokens = append(s.instanceTokens, instanceToken{index: index})
+}
+
+func (s *programState) popInstanceToken() {
+	s.instanceTokens = s.instanceTokens[:len(s.instanceTokens)-1]
+}
+
+// pushKeys returns the keys of object, in sorted order, so that errors are
+// reported in a deterministic order. The keys are stored at the end of s.keys,
+// which is reused across validations, and must be released with popKeys.
+func (s *programState) pushKeys(object map[string]interface{}) []string {
+	start := len(s.keys)
+	for k := range object {
+		s.keys = append(s.keys, k)
+	}
+
+	keys := s.keys[start:]
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *programState) popKeys(keys []string) {
+	s.keys = s.keys[:len(s.keys)-len(keys)]
+}
+
+// compiler turns Schemas into evalFuncs.
+//
+// Schemas which are the target of a ref are compiled at most once, which is
+// what allows recursive schemas to be compiled at all.
+type compiler struct {
+	refs map[*Schema]*evalFunc
+}
+
+// schemaPtr returns a pointer made of tokens followed by extra. The returned
+// pointer is shared by every error it is reported in, so its capacity is
+// clamped to make appending to it copy.
+func schemaPtr(tokens []string, extra ...string) jsonpointer.Ptr {
+	out := make([]string, 0, len(tokens)+len(extra))
+	out = append(out, tokens...)
+	out = append(out, extra...)
+	return jsonpointer.Ptr{Tokens: out[:len(out):len(out)]}
+}
+
+func (c *compiler) compile(schema *Schema, uri *url.URL, tokens []string) evalFunc {
+	return c.compileTagged(schema, uri, tokens, nil)
+}
+
+// compileTagged compiles a schema. If tag is non-nil, the schema is the value
+// of a discriminator mapping, and tag is the discriminator's property name.
+func (c *compiler) compileTagged(schema *Schema, uri *url.URL, tokens []string, tag *string) evalFunc {
+	if schema.Nullable {
+		fn := c.compileNonNull(schema, uri, tokens, tag)
+		return func(s *programState, instance interface{}) error {
+			instance, err := jsonValue(instance)
+			if err != nil {
+				return err
+			}
+
+			if instance == nil {
+				return nil
+			}
+
+			return fn(s, instance)
+		}
+	}
+
+	return c.compileNonNull(schema, uri, tokens, tag)
+}
+
+// compileNonNull compiles a schema, ignoring whether it is nullable.
+func (c *compiler) compileNonNull(schema *Schema, uri *url.URL, tokens []string, tag *string) evalFunc {
+	if schema.RefSchema != nil {
+		return c.compileRef(schema)
+	}
+
+	switch schema.Kind {
+	case SchemaKindType:
+		return compileType(schema.Type, uri, schemaPtr(tokens, "type"), customMessage(schema, "type"))
+	case SchemaKindEnum:
+		return compileEnum(schema.Enum, uri, schemaPtr(tokens, "enum"), customMessage(schema, "enum"))
+	case SchemaKindElements:
+		return c.compileElements(schema, uri, tokens)
+	case SchemaKindProperties:
+		return c.compileProperties(schema, uri, tokens, tag)
+	case SchemaKindValues:
+		return c.compileValues(schema, uri, tokens)
+	case SchemaKindDiscriminator:
+		return c.compileDiscriminator(schema, uri, tokens)
+	}
+
+	return func(s *programState, instance interface{}) error {
+		return nil
+	}
+}
+
+func (c *compiler) compileRef(schema *Schema) evalFunc {
+	target, ok := c.refs[schema.RefSchema]
+	if !ok {
+		tokens := []string{}
+		if schema.Ref.Fragment != "" {
+			tokens = []string{"definitions", schema.Ref.Fragment}
+		}
+
+		target = new(evalFunc)
+		c.refs[schema.RefSchema] = target
+		*target = c.compile(schema.RefSchema, schema.RefSchema.Base, tokens)
+	}
+
+	return func(s *programState, instance interface{}) error {
+		if s.depth == s.maxDepth {
+			return ErrMaxDepth
+		}
+
+		s.depth++
+		if err := (*target)(s, instance); err != nil {
+			return err
+		}
+		s.depth--
+
+		return nil
+	}
+}
+
+func compileType(t SchemaType, uri *url.URL, ptr jsonpointer.Ptr, message string) evalFunc {
+	return func(s *programState, instance interface{}) error {
+		instance, err := jsonValue(instance)
+		if err != nil {
+			return err
+		}
+
+		if !typeMatches(t, instance, s.strictNumbers) {
+			return s.reportError(uri, ptr, message)
+		}
+
+		return nil
+	}
+}
+
+func compileEnum(enum map[string]struct{}, uri *url.URL, ptr jsonpointer.Ptr, message string) evalFunc {
+	return func(s *programState, instance interface{}) error {
+		instance, err := jsonValue(instance)
+		if err != nil {
+			return err
+		}
+
+		if !enumContains(enum, instance) {
+			return s.reportError(uri, ptr, message)
+		}
+
+		return nil
+	}
+}
+
+func (c *compiler) compileElements(schema *Schema, uri *url.URL, tokens []string) evalFunc {
+	ptr := schemaPtr(tokens, "elements")
+	message := customMessage(schema, "elements")
+	elements := c.compile(schema.Elements, uri, ptr.Tokens)
+
+	return func(s *programState, instance interface{}) error {
+		instance, err := jsonValue(instance)
+		if err != nil {
+			return err
+		}
+
+		elems, ok := instance.([]interface{})
+		if !ok {
+			return s.reportError(uri, ptr, message)
+		}
+
+		if err := s.checkInstanceDepth(); err != nil {
+			return err
+		}
+
+		for i, elem := range elems {
+			s.pushIndex(i)
+			if err := elements(s, elem); err != nil {
+				return err
+			}
+			s.popInstanceToken()
+		}
+
+		return nil
+	}
+}
+
+// errorSite is somewhere a compiled schema may report an error, along with the
+// CustomMessage the schema configures for it.
+type errorSite struct {
+	ptr     jsonpointer.Ptr
+	message string
+}
+
+// compiledProperty is a compiled member of "properties" or
+// "optionalProperties".
+type compiledProperty struct {
+	name   string
+	ptr    jsonpointer.Ptr // where to report the property as missing
+	schema evalFunc
+}
+
+func (c *compiler) compileProperties(schema *Schema, uri *url.URL, tokens []string, tag *string) evalFunc {
+	required := make([]compiledProperty, 0, len(schema.Properties))
+	for _, name := range sortedSchemaKeys(schema.Properties) {
+		ptr := schemaPtr(tokens, "properties", name)
+		required = append(required, compiledProperty{
+			name:   name,
+			ptr:    ptr,
+			schema: c.compile(schema.Properties[name], uri, ptr.Tokens),
+		})
+	}
+
+	optional := make([]compiledProperty, 0, len(schema.OptionalProperties))
+	for _, name := range sortedSchemaKeys(schema.OptionalProperties) {
+		ptr := schemaPtr(tokens, "optionalProperties", name)
+		optional = append(optional, compiledProperty{
+			name:   name,
+			ptr:    ptr,
+			schema: c.compile(schema.OptionalProperties[name], uri, ptr.Tokens),
+		})
+	}
+
+	// Which errors a non-object produces depends on which keywords appeared in
+	// the schema.
+	var notObject []errorSite
+	if schema.Properties != nil {
+		notObject = append(notObject, errorSite{schemaPtr(tokens, "properties"), customMessage(schema, "properties")})
+	}
+
+	if schema.OptionalProperties != nil {
+		notObject = append(notObject, errorSite{schemaPtr(tokens, "optionalProperties"), customMessage(schema, "optionalProperties")})
+	}
+
+	requiredMessage := customMessage(schema, "properties")
+	additionalPtr := schemaPtr(tokens, "additionalProperties")
+	additionalMessage := customMessage(schema, "additionalProperties")
+
+	return func(s *programState, instance interface{}) error {
+		instance, err := jsonValue(instance)
+		if err != nil {
+			return err
+		}
+
+		object, ok := instance.(map[string]interface{})
+		if !ok {
+			for _, site := range notObject {
+				if err := s.reportError(uri, site.ptr, site.message); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		if err := s.checkInstanceDepth(); err != nil {
+			return err
+		}
+
+		for _, p := range required {
+			if value, ok := object[p.name]; ok {
+				s.pushProperty(p.name)
+				if err := p.schema(s, value); err != nil {
+					return err
+				}
+				s.popInstanceToken()
+			} else {
+				if err := s.reportError(uri, p.ptr, requiredMessage); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, p := range optional {
+			if value, ok := object[p.name]; ok {
+				s.pushProperty(p.name)
+				if err := p.schema(s, value); err != nil {
+					return err
+				}
+				s.popInstanceToken()
+			}
+		}
+
+		if allowsAdditionalProperties(schema, s.disallowAdditional) {
+			return nil
+		}
+
+		keys := s.pushKeys(object)
+		for _, property := range keys {
+			if isDeclaredProperty(schema, property) || (tag != nil && property == *tag) {
+				continue
+			}
+
+			s.pushProperty(property)
+			if err := s.reportErrorAndPop(uri, additionalPtr, additionalMessage); err != nil {
+				return err
+			}
+		}
+		s.popKeys(keys)
+
+		return nil
+	}
+}
+
+func (c *compiler) compileValues(schema *Schema, uri *url.URL, tokens []string) evalFunc {
+	ptr := schemaPtr(tokens, "values")
+	message := customMessage(schema, "values")
+	values := c.compile(schema.Values, uri, ptr.Tokens)
+
+	return func(s *programState, instance interface{}) error {
+		instance, err := jsonValue(instance)
+		if err != nil {
+			return err
+		}
+
+		object, ok := instance.(map[string]interface{})
+		if !ok {
+			return s.reportError(uri, ptr, message)
+		}
+
+		if err := s.checkInstanceDepth(); err != nil {
+			return err
+		}
+
+		keys := s.pushKeys(object)
+		for _, key := range keys {
+			s.pushProperty(key)
+			if err := values(s, object[key]); err != nil {
+				return err
+			}
+			s.popInstanceToken()
+		}
+		s.popKeys(keys)
+
+		return nil
+	}
+}
+
+func (c *compiler) compileDiscriminator(schema *Schema, uri *url.URL, tokens []string) evalFunc {
+	ptr := schemaPtr(tokens, "discriminator")
+	propertyNamePtr := schemaPtr(ptr.Tokens, "propertyName")
+	mappingPtr := schemaPtr(ptr.Tokens, "mapping")
+	propertyName := schema.DiscriminatorPropertyName
+	message := customMessage(schema, "discriminator")
+
+	mapping := make(map[string]evalFunc, len(schema.DiscriminatorMapping))
+	for tag, subSchema := range schema.DiscriminatorMapping {
+		mapping[tag] = c.compileTagged(subSchema, uri, schemaPtr(mappingPtr.Tokens, tag).Tokens, &propertyName)
+	}
+
+	return func(s *programState, instance interface{}) error {
+		instance, err := jsonValue(instance)
+		if err != nil {
+			return err
+		}
+
+		object, ok := instance.(map[string]interface{})
+		if !ok {
+			return s.reportError(uri, ptr, message)
+		}
+
+		prop, ok := object[propertyName]
+		if !ok {
+			return s.reportError(uri, propertyNamePtr, message)
+		}
+
+		prop, err = jsonValue(prop)
+		if err != nil {
+			return err
+		}
+
+		s.pushProperty(propertyName)
+		propStr, ok := prop.(string)
+		if !ok {
+			return s.reportErrorAndPop(uri, propertyNamePtr, message)
+		}
+
+		subSchema, ok := mapping[propStr]
+		if !ok {
+			return s.reportErrorAndPop(uri, mappingPtr, message)
+		}
+		s.popInstanceToken()
+
+		return subSchema(s, instance)
+	}
+}
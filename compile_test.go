@@ -0,0 +1,213 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgram(t *testing.T) {
+	testCases := []struct {
+		registry  []string
+		instances []string
+	}{
+		{
+			[]string{`{}`},
+			[]string{`null`, `[]`, `{"a":1}`},
+		},
+		{
+			[]string{`{"type":"number"}`},
+			[]string{`null`, `1`, `"a"`},
+		},
+		{
+			[]string{`{"definitions":{"a":{"type":"string","nullable":true}},"elements":{"nullable":true,"ref":"#a"}}`},
+			[]string{`null`, `[null,"a",1]`},
+		},
+		{
+			[]string{`{"nullable":true,"discriminator":{"propertyName":"t","mapping":{"a":{"properties":{"x":{"type":"string","nullable":true}}}}}}`},
+			[]string{`null`, `{}`, `{"t":"a","x":null}`, `{"t":"a","x":1}`},
+		},
+		{
+			[]string{`{"nullable":true,"properties":{"a":{"values":{"nullable":true,"type":"number"}}}}`},
+			[]string{`null`, `{"a":null}`, `{"a":{"b":null,"c":1,"d":"e"}}`},
+		},
+		{
+			[]string{`{"properties":{"a":{}},"optionalProperties":{"b":{}},"additionalProperties":false}`},
+			[]string{`{"a":1}`, `{"a":1,"b":2,"c":3,"d":[4]}`},
+		},
+		{
+			[]string{`{"elements":{"discriminator":{"propertyName":"t","mapping":{"a":{"properties":{"x":{"additionalProperties":false,"properties":{}}},"additionalProperties":false}}}}}`},
+			[]string{`[{"t":"a","x":{}},{"t":"a","x":{"t":"a"},"y":1}]`},
+		},
+		{
+			[]string{`{"enum":["a","b"]}`},
+			[]string{`null`, `"a"`, `"c"`, `["a"]`, `{"a":"b"}`},
+		},
+		{
+			[]string{`{"elements":{"type":"string"}}`},
+			[]string{`null`, `[]`, `["a",1,"b",null]`},
+		},
+		{
+			[]string{`{"properties":{"a":{"type":"string"},"b":{}},"optionalProperties":{"c":{"type":"boolean"}}}`},
+			[]string{`null`, `{}`, `{"a":1,"c":1}`, `{"a":"a","b":null,"c":true}`},
+		},
+		{
+			[]string{`{"values":{"type":"null"}}`},
+			[]string{`[]`, `{}`, `{"a":1,"b":null,"c":true}`},
+		},
+		{
+			[]string{`{"discriminator":{"propertyName":"t","mapping":{"a":{"properties":{"x":{"type":"string"}}}}}}`},
+			[]string{`[]`, `{}`, `{"t":1}`, `{"t":"b"}`, `{"t":"a"}`, `{"t":"a","x":"x"}`},
+		},
+		{
+			[]string{
+				`{"id":"http://example.com/bar","definitions":{"s":{"type":"string"}}}`,
+				`{"definitions":{"tree":{"properties":{"v":{"ref":"http://example.com/bar#s"},"c":{"elements":{"ref":"#tree"}}}}},"ref":"#tree"}`,
+			},
+			[]string{`{}`, `{"v":"a","c":[]}`, `{"v":"a","c":[{"v":1,"c":[{}]}]}`},
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			schemas := make([]SchemaStruct, len(tt.registry))
+			for i, s := range tt.registry {
+				assert.NoError(t, json.Unmarshal([]byte(s), &schemas[i]))
+			}
+
+			registry, err := NewRegistry(schemas)
+			assert.NoError(t, err)
+
+			program, err := registry.Compile(url.URL{})
+			assert.NoError(t, err)
+
+			validator := Validator{Registry: registry}
+			for _, s := range tt.instances {
+				var instance interface{}
+				assert.NoError(t, json.Unmarshal([]byte(s), &instance))
+
+				expected, err := validator.Validate(instance)
+				assert.NoError(t, err)
+
+				actual, err := program.Validate(instance)
+				assert.NoError(t, err)
+
+				assert.Equal(t, expected, actual, s)
+			}
+		})
+	}
+}
+
+func TestProgramMaxDepth(t *testing.T) {
+	ref := "#a"
+	registry, err := NewRegistry([]SchemaStruct{
+		SchemaStruct{
+			Definitions: &map[string]SchemaStruct{
+				"a": SchemaStruct{Elements: &SchemaStruct{Ref: &ref}},
+			},
+			Ref: &ref,
+		},
+	})
+	assert.NoError(t, err)
+
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	var instance interface{}
+	for i := 0; i < 64; i++ {
+		instance = []interface{}{instance}
+	}
+
+	program.MaxDepth = 32
+	_, err = program.Validate(instance)
+	assert.Equal(t, ErrMaxDepth, err)
+}
+
+func TestProgramMissingSchema(t *testing.T) {
+	registry, err := NewRegistry([]SchemaStruct{})
+	assert.NoError(t, err)
+
+	_, err = registry.Compile(url.URL{})
+	assert.Error(t, err)
+}
+
+const benchmarkSchema = `{
+	"properties": {
+		"id": {"type": "string"},
+		"createdAt": {"type": "string"},
+		"tags": {"elements": {"type": "string"}},
+		"attributes": {"values": {"type": "number"}},
+		"event": {
+			"discriminator": {
+				"propertyName": "type",
+				"mapping": {
+					"click": {"properties": {"x": {"type": "number"}, "y": {"type": "number"}}},
+					"view": {"properties": {"url": {"type": "string"}}}
+				}
+			}
+		}
+	},
+	"optionalProperties": {
+		"deleted": {"type": "boolean"}
+	}
+}`
+
+const benchmarkInstance = `{
+	"id": "6d4a51ed",
+	"createdAt": "2019-01-01T00:00:00Z",
+	"tags": ["a", "b", "c", "d"],
+	"attributes": {"a": 1, "b": 2, "c": 3},
+	"event": {"type": "click", "x": 10, "y": 20},
+	"deleted": false
+}`
+
+func benchmarkSetup(b *testing.B) (Registry, interface{}) {
+	var schema SchemaStruct
+	if err := json.Unmarshal([]byte(benchmarkSchema), &schema); err != nil {
+		b.Fatal(err)
+	}
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	var instance interface{}
+	if err := json.Unmarshal([]byte(benchmarkInstance), &instance); err != nil {
+		b.Fatal(err)
+	}
+
+	return registry, instance
+}
+
+func BenchmarkValidatorValidate(b *testing.B) {
+	registry, instance := benchmarkSetup(b)
+	validator := Validator{Registry: registry}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := validator.Validate(instance); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProgramValidate(b *testing.B) {
+	registry, instance := benchmarkSetup(b)
+	program, err := registry.Compile(url.URL{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := program.Validate(instance); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
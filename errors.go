@@ -4,12 +4,26 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
+
+	"github.com/json-validate/json-pointer-go"
 )
 
 var ErrBadSubSchema = errors.New("invalid sub-schema")
 var ErrBadSchemaKind = errors.New("invalid keyword combination")
 var ErrMaxDepth = errors.New("max recursion depth reached during validation")
+var ErrMaxInstanceDepth = errors.New("max instance depth reached during validation")
+var ErrMaxResolved = errors.New("max resolved schemas reached")
 var errMaxErrors = errors.New("max errors reached")
+var errDone = errors.New("context done")
+
+type ErrUnknownKeyword struct {
+	Keyword string
+}
+
+func (e ErrUnknownKeyword) Error() string {
+	return fmt.Sprintf("unknown keyword: %s", e.Keyword)
+}
 
 type ErrMissingSchemas struct {
 	URIs []url.URL
@@ -18,3 +32,157 @@ type ErrMissingSchemas struct {
 func (e ErrMissingSchemas) Error() string {
 	return fmt.Sprintf("missing schemas: %v", e.URIs)
 }
+
+// ErrSchemaNotFound is returned by a SchemaResolver which has no schema with
+// the URI it was asked for.
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// ErrResolve is returned by NewRegistryWithOptions when a schema could not be
+// fetched from its Resolver, or the schema fetched is invalid. In the latter
+// case, Err is SchemaErrors, and the Index of each one is zero.
+type ErrResolve struct {
+	URI url.URL
+	Err error
+}
+
+func (e ErrResolve) Error() string {
+	return fmt.Sprintf("resolving %s: %v", e.URI.String(), e.Err)
+}
+
+// Cause returns the underlying error. It satisfies the interface used by
+// github.com/pkg/errors.Cause.
+func (e ErrResolve) Cause() error {
+	return e.Err
+}
+
+// ErrDuplicateID is the error of a SchemaError for a schema with the same "id"
+// as an earlier schema. FirstIndex is the index of the earlier schema.
+type ErrDuplicateID struct {
+	ID         url.URL
+	FirstIndex int
+}
+
+func (e ErrDuplicateID) Error() string {
+	return fmt.Sprintf("duplicate id: %#v (first used by schema at index %d)", e.ID.String(), e.FirstIndex)
+}
+
+// ErrRefCycle is returned by NewRegistry for schemas which refer to one another
+// in a cycle that never consumes any of the instance being validated, such as
+// a definition whose "ref" is to itself. URIs are the refs which make up the
+// cycle, in the order they are followed.
+type ErrRefCycle struct {
+	URIs []url.URL
+}
+
+func (e ErrRefCycle) Error() string {
+	uris := make([]string, len(e.URIs)+1)
+	for i, uri := range e.URIs {
+		uris[i] = uri.String()
+	}
+
+	uris[len(e.URIs)] = uris[0]
+	return fmt.Sprintf("ref cycle: %s", strings.Join(uris, " -> "))
+}
+
+// SchemaErrorCode indicates what is wrong with a schema.
+type SchemaErrorCode int
+
+const (
+	// SchemaErrorCodeInvalidURI indicates an "id" or "ref" which isn't a valid
+	// URI.
+	SchemaErrorCodeInvalidURI SchemaErrorCode = iota + 1
+
+	// SchemaErrorCodeBadSubSchema indicates a keyword which may only appear in
+	// a root schema, such as "id" or "definitions", in a sub-schema.
+	SchemaErrorCodeBadSubSchema
+
+	// SchemaErrorCodeBadKind indicates a keyword which may not be used
+	// alongside the keywords before it.
+	SchemaErrorCodeBadKind
+
+	// SchemaErrorCodeInvalidType indicates an unknown value for "type".
+	SchemaErrorCodeInvalidType
+
+	// SchemaErrorCodeInvalidEnum indicates an "enum" which is empty or has
+	// duplicate values.
+	SchemaErrorCodeInvalidEnum
+
+	// SchemaErrorCodeUnknownKeyword indicates a keyword which is not part of
+	// the spec, when parsing in strict mode.
+	SchemaErrorCodeUnknownKeyword
+
+	// SchemaErrorCodeInvalidDiscriminator indicates a "discriminator" whose
+	// "propertyName" is empty, or whose "mapping" has a value which isn't a
+	// "properties" schema or which declares the discriminator's property.
+	SchemaErrorCodeInvalidDiscriminator
+
+	// SchemaErrorCodeDuplicateID indicates a schema with the same "id" as an
+	// earlier schema, unless RegistryOptions.ReplaceDuplicateIDs is set.
+	SchemaErrorCodeDuplicateID
+)
+
+// SchemaError is a problem with a schema passed to NewRegistry.
+type SchemaError struct {
+	// The index of the offending schema in the slice passed to NewRegistry.
+	Index int
+
+	// The "id" of the offending schema, or the empty string if it has none.
+	ID string
+
+	// Where in the offending schema the problem is. Ptr refers to the object
+	// containing Keyword: usually a schema, but for problems with the contents
+	// of "discriminator", the discriminator or its mapping.
+	Ptr jsonpointer.Ptr
+
+	// The keyword which has a problem.
+	Keyword string
+
+	// What sort of problem there is.
+	Code SchemaErrorCode
+
+	// The underlying error, such as ErrBadSchemaKind or a *url.Error.
+	Err error
+
+	// Where in the source of the offending schema the problem is. This is only
+	// set by SchemaErrors.AddPositions.
+	Position *Position
+}
+
+func (e SchemaError) Error() string {
+	id := ""
+	if e.ID != "" {
+		id = fmt.Sprintf(" (id: %s)", e.ID)
+	}
+
+	pos := ""
+	if e.Position != nil {
+		pos = fmt.Sprintf(" (%s)", e.Position)
+	}
+
+	return fmt.Sprintf("schema at index %d%s: %#v: %s: %v%s", e.Index, id, e.Ptr.String(), e.Keyword, e.Err, pos)
+}
+
+// Cause returns the underlying error. It satisfies the interface used by
+// github.com/pkg/errors.Cause.
+func (e SchemaError) Cause() error {
+	return e.Err
+}
+
+// SchemaErrors is returned by NewRegistry when any of its schemas are invalid.
+// It contains every problem found, in order of the schemas' indices.
+type SchemaErrors []SchemaError
+
+func (e SchemaErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Cause returns the underlying error of the first SchemaError. It satisfies
+// the interface used by github.com/pkg/errors.Cause.
+func (e SchemaErrors) Cause() error {
+	return e[0].Err
+}
@@ -0,0 +1,286 @@
+package jsonvalidate
+
+import (
+	"sort"
+	"strconv"
+)
+
+// ErrorMessageKeyword is the metadata keyword which configures custom error
+// messages. Its value may be a string, which is used for any error reported by
+// the schema it appears in, or an object mapping keywords of that schema, such
+// as "type" or "properties", to a string to use for errors reported by that
+// keyword. Values of any other form are ignored.
+const ErrorMessageKeyword = "errorMessage"
+
+// ErrorKind indicates what sort of problem a ValidationError reports.
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown indicates an error which has not been explained.
+	ErrorKindUnknown ErrorKind = iota
+
+	// ErrorKindType indicates a value which does not match "type".
+	ErrorKindType
+
+	// ErrorKindEnum indicates a value which is not in "enum".
+	ErrorKindEnum
+
+	// ErrorKindNotArray indicates a value which is not an array, when
+	// "elements" requires one.
+	ErrorKindNotArray
+
+	// ErrorKindNotObject indicates a value which is not an object, when
+	// "properties", "optionalProperties", "values" or "discriminator" requires
+	// one.
+	ErrorKindNotObject
+
+	// ErrorKindMissingProperty indicates an object which lacks a property
+	// required by "properties".
+	ErrorKindMissingProperty
+
+	// ErrorKindAdditionalProperty indicates an object property which is not
+	// permitted by "additionalProperties".
+	ErrorKindAdditionalProperty
+
+	// ErrorKindMissingDiscriminator indicates an object which lacks the
+	// discriminator property.
+	ErrorKindMissingDiscriminator
+
+	// ErrorKindDiscriminatorNotString indicates a discriminator property whose
+	// value is not a string.
+	ErrorKindDiscriminatorNotString
+
+	// ErrorKindBadDiscriminator indicates a discriminator property whose value
+	// is not in "mapping".
+	ErrorKindBadDiscriminator
+)
+
+// Explanation describes the problem which a ValidationError reports, in a form
+// which a MessageCatalog can turn into a message.
+type Explanation struct {
+	// What sort of problem there is.
+	Kind ErrorKind
+
+	// The offending value from the instance, as it would be decoded from JSON.
+	// For ErrorKindMissingProperty and ErrorKindMissingDiscriminator, this is
+	// the object lacking the property.
+	Value interface{}
+
+	// What was expected instead of Value, in sorted order. This is the type
+	// name for ErrorKindType, the permitted values for ErrorKindEnum, and the
+	// keys of "mapping" for ErrorKindBadDiscriminator.
+	Expected []string
+
+	// The name of the property which is missing, unexpected or used as the
+	// discriminator, for those kinds of errors.
+	Property string
+}
+
+// Explain sets the Explanation of each of the errors in r. registry must be
+// the registry, and instance the value, which produced r.
+func (r *ValidationResult) Explain(registry Registry, instance interface{}) {
+	for i := range r.Errors {
+		r.Errors[i].Explain(registry, instance)
+	}
+}
+
+// Explain sets Explanation, by looking at the part of the schema at SchemaPath
+// and the part of instance at InstancePath. registry must be the registry, and
+// instance the value, which produced e.
+func (e *ValidationError) Explain(registry Registry, instance interface{}) {
+	root, ok := registry.Schemas[e.SchemaURI]
+	if !ok {
+		return
+	}
+
+	schema, keyword, ok := schemaAt(root, e.SchemaPath.Tokens)
+	if !ok {
+		return
+	}
+
+	e.Explanation = explain(*e, schema, keyword, instance)
+}
+
+// Message returns a human-readable description of the error in English. This
+// is CustomMessage if it is set, and otherwise a generated message such as
+// "expected string, got number 3". Unless Explain has been called, the
+// message only says which part of the schema was not satisfied.
+func (e ValidationError) Message() string {
+	return e.MessageIn(DefaultCatalog)
+}
+
+// MessageIn is like Message, but generates the message using catalog.
+func (e ValidationError) MessageIn(catalog MessageCatalog) string {
+	if e.CustomMessage != "" {
+		return e.CustomMessage
+	}
+
+	return catalog.Message(e)
+}
+
+// customMessage returns the message which schema configures for errors reported
+// by keyword, or the empty string if there is none.
+func customMessage(schema *Schema, keyword string) string {
+	switch message := schema.Extra[ErrorMessageKeyword].(type) {
+	case string:
+		return message
+	case map[string]interface{}:
+		if message, ok := message[keyword].(string); ok {
+			return message
+		}
+	}
+
+	return ""
+}
+
+// explain describes e, which schema reported at keyword. It returns nil if the
+// error can't be explained.
+func explain(e ValidationError, schema *Schema, keyword []string, instance interface{}) *Explanation {
+	value, ok := instanceAt(instance, e.InstancePath.Tokens)
+	if !ok {
+		return nil
+	}
+
+	out := Explanation{Value: value}
+	switch keyword[0] {
+	case "type":
+		out.Kind = ErrorKindType
+		out.Expected = []string{schema.Type.String()}
+	case "enum":
+		out.Kind = ErrorKindEnum
+		out.Expected = make([]string, 0, len(schema.Enum))
+		for k := range schema.Enum {
+			out.Expected = append(out.Expected, k)
+		}
+
+		sort.Strings(out.Expected)
+	case "elements":
+		out.Kind = ErrorKindNotArray
+	case "properties":
+		out.Kind = ErrorKindNotObject
+		if len(keyword) == 2 {
+			out.Kind = ErrorKindMissingProperty
+			out.Property = keyword[1]
+		}
+	case "optionalProperties", "values":
+		out.Kind = ErrorKindNotObject
+	case "additionalProperties":
+		out.Kind = ErrorKindAdditionalProperty
+		out.Property = e.InstancePath.Tokens[len(e.InstancePath.Tokens)-1]
+	case "discriminator":
+		out.Kind = ErrorKindNotObject
+		if len(keyword) == 1 {
+			break
+		}
+
+		out.Property = schema.DiscriminatorPropertyName
+		switch keyword[1] {
+		case "propertyName":
+			// A missing property is reported at the object, whereas one which
+			// is not a string is reported at the property itself.
+			out.Kind = ErrorKindMissingDiscriminator
+			if tokens := e.InstancePath.Tokens; len(tokens) > 0 && tokens[len(tokens)-1] == out.Property {
+				out.Kind = ErrorKindDiscriminatorNotString
+			}
+		case "mapping":
+			out.Kind = ErrorKindBadDiscriminator
+			out.Expected = make([]string, 0, len(schema.DiscriminatorMapping))
+			for k := range schema.DiscriminatorMapping {
+				out.Expected = append(out.Expected, k)
+			}
+
+			sort.Strings(out.Expected)
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	return &out
+}
+
+// schemaAt follows tokens from root into the sub-schema whose keyword an error
+// was reported at. It returns that sub-schema, along with the tokens which
+// remain: the keyword itself, and whatever follows it.
+func schemaAt(root *Schema, tokens []string) (*Schema, []string, bool) {
+	schema := root
+	for len(tokens) > 0 {
+		// n is how many tokens lead into the next sub-schema, if tokens[0] is not
+		// the keyword the error was reported at.
+		var next *Schema
+		n := 0
+
+		switch tokens[0] {
+		case "definitions":
+			if len(tokens) > 2 {
+				next, n = schema.Definitions[tokens[1]], 2
+			}
+		case "elements":
+			if len(tokens) > 1 {
+				next, n = schema.Elements, 1
+			}
+		case "values":
+			if len(tokens) > 1 {
+				next, n = schema.Values, 1
+			}
+		case "properties":
+			if len(tokens) > 2 {
+				next, n = schema.Properties[tokens[1]], 2
+			}
+		case "optionalProperties":
+			if len(tokens) > 2 {
+				next, n = schema.OptionalProperties[tokens[1]], 2
+			}
+		case "discriminator":
+			if len(tokens) > 3 && tokens[1] == "mapping" {
+				next, n = schema.DiscriminatorMapping[tokens[2]], 3
+			}
+		}
+
+		if n == 0 {
+			return schema, tokens, true
+		}
+
+		if next == nil {
+			return nil, nil, false
+		}
+
+		schema = next
+		tokens = tokens[n:]
+	}
+
+	return nil, nil, false
+}
+
+// instanceAt returns the part of instance which tokens refer to.
+func instanceAt(instance interface{}, tokens []string) (interface{}, bool) {
+	for _, token := range tokens {
+		value, err := jsonValue(instance)
+		if err != nil {
+			return nil, false
+		}
+
+		switch value := value.(type) {
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil, false
+			}
+
+			instance = value[i]
+		case map[string]interface{}:
+			elem, ok := value[token]
+			if !ok {
+				return nil, false
+			}
+
+			instance = elem
+		default:
+			return nil, false
+		}
+	}
+
+	value, err := jsonValue(instance)
+	return value, err == nil
+}
@@ -0,0 +1,232 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/json-validate/json-pointer-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplain(t *testing.T) {
+	testCases := []struct {
+		schema   string
+		instance string
+		messages []string
+	}{
+		{
+			`{"type":"string"}`,
+			`3`,
+			[]string{"expected string, got number 3"},
+		},
+		{
+			`{"type":"uint8"}`,
+			`300`,
+			[]string{"expected uint8, got number 300"},
+		},
+		{
+			`{"enum":["b","a"]}`,
+			`"c"`,
+			[]string{`expected one of "a", "b", got string "c"`},
+		},
+		{
+			`{"elements":{"type":"boolean"}}`,
+			`{}`,
+			[]string{"expected array, got object"},
+		},
+		{
+			`{"elements":{"type":"boolean"}}`,
+			`[true,null]`,
+			[]string{"expected boolean, got null"},
+		},
+		{
+			`{"properties":{"a":{}},"optionalProperties":{"b":{}}}`,
+			`[]`,
+			[]string{"expected object, got array", "expected object, got array"},
+		},
+		{
+			`{"properties":{"a":{}},"additionalProperties":false}`,
+			`{"b":1}`,
+			[]string{`missing required property "a"`, `unexpected property "b"`},
+		},
+		{
+			`{"values":{"type":"string"}}`,
+			`{"a":"x","b":false}`,
+			[]string{"expected string, got boolean false"},
+		},
+		{
+			`{"discriminator":{"propertyName":"t","mapping":{"x":{"properties":{"a":{"type":"string"}}}}}}`,
+			`[{}, {"t":1}, {"t":"y"}, {"t":"x","a":1}, {"t":{}}]`,
+			[]string{
+				`missing discriminator property "t"`,
+				`expected discriminator property "t" to be a string, got number 1`,
+				`expected discriminator property "t" to be one of "x", got string "y"`,
+				"expected string, got number 1",
+				`expected discriminator property "t" to be a string, got object`,
+			},
+		},
+		{
+			`{"definitions":{"a":{"type":"string"}},"properties":{"a":{"ref":"#a"}}}`,
+			`{"a":null}`,
+			[]string{"expected string, got null"},
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var schema SchemaStruct
+			assert.NoError(t, json.Unmarshal([]byte(tt.schema), &schema))
+
+			// Discriminator cases validate each element of an array separately.
+			if schema.Discriminator != nil {
+				elements := schema
+				schema = SchemaStruct{Elements: &elements}
+			}
+
+			registry, err := NewRegistry([]SchemaStruct{schema})
+			assert.NoError(t, err)
+
+			var instance interface{}
+			assert.NoError(t, json.Unmarshal([]byte(tt.instance), &instance))
+
+			result, err := Validator{Registry: registry}.Validate(instance)
+			assert.NoError(t, err)
+
+			result.Explain(registry, instance)
+
+			messages := make([]string, len(result.Errors))
+			for i, err := range result.Errors {
+				messages[i] = err.Message()
+			}
+
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestMessageWithoutExplain(t *testing.T) {
+	err := ValidationError{SchemaPath: jsonpointer.Ptr{Tokens: []string{"elements", "type"}}}
+	assert.Equal(t, `value does not satisfy "/elements/type"`, err.Message())
+}
+
+func TestExplainCustomMessage(t *testing.T) {
+	testCases := []struct {
+		schema   string
+		instance string
+		messages []string
+	}{
+		{
+			`{"type":"string","errorMessage":"Please enter a valid email"}`,
+			`3`,
+			[]string{"Please enter a valid email"},
+		},
+		{
+			`{"properties":{"a":{"type":"string"}},"additionalProperties":false,"errorMessage":{"properties":"a is required"}}`,
+			`{"b":1}`,
+			[]string{"a is required", `unexpected property "b"`},
+		},
+		{
+			`{"elements":{"enum":["a"],"errorMessage":{"enum":"Pick a"}}}`,
+			`["b",1]`,
+			[]string{"Pick a", "Pick a"},
+		},
+		{
+			`{"type":"string","errorMessage":3}`,
+			`3`,
+			[]string{"expected string, got number 3"},
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var schema SchemaStruct
+			assert.NoError(t, json.Unmarshal([]byte(tt.schema), &schema))
+
+			registry, err := NewRegistryWithOptions([]SchemaStruct{schema}, RegistryOptions{Strict: true})
+			assert.NoError(t, err)
+
+			var instance interface{}
+			assert.NoError(t, json.Unmarshal([]byte(tt.instance), &instance))
+
+			result, err := Validator{Registry: registry}.Validate(instance)
+			assert.NoError(t, err)
+
+			result.Explain(registry, instance)
+
+			messages := make([]string, len(result.Errors))
+			for i, err := range result.Errors {
+				messages[i] = err.Message()
+			}
+
+			assert.Equal(t, tt.messages, messages)
+		})
+	}
+}
+
+func TestCustomMessageWithoutExplain(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"a": {"type": "string", "errorMessage": "a must be a string"},
+			"b": {"elements": {}, "errorMessage": {"elements": "b must be an array"}},
+			"c": {"discriminator": {"propertyName": "t", "mapping": {}}, "errorMessage": "c is bad"}
+		},
+		"additionalProperties": false,
+		"errorMessage": {"additionalProperties": "no extras", "properties": "missing something"}
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	instance := `{"a": 1, "b": {}, "x": null}`
+	expected := []string{"a must be a string", "b must be an array", "missing something", "no extras"}
+
+	messages := func(result ValidationResult) []string {
+		out := make([]string, len(result.Errors))
+		for i, err := range result.Errors {
+			out[i] = err.CustomMessage
+		}
+
+		return out
+	}
+
+	var value interface{}
+	assert.NoError(t, json.Unmarshal([]byte(instance), &value))
+
+	validator := Validator{Registry: registry}
+	result, err := validator.Validate(value)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, messages(result))
+
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	result, err = program.Validate(value)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, messages(result))
+
+	// ValidateReader reports errors in the order the instance is read in.
+	result, err = validator.ValidateReader(strings.NewReader(instance))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a must be a string", "b must be an array", "no extras", "missing something"}, messages(result))
+
+	// Discriminators are checked on the whole value, even when streaming.
+	instance = `{"a": "", "b": [], "c": {"t": 3}}`
+	expected = []string{"c is bad"}
+	assert.NoError(t, json.Unmarshal([]byte(instance), &value))
+
+	result, err = validator.Validate(value)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, messages(result))
+
+	result, err = program.Validate(value)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, messages(result))
+
+	result, err = validator.ValidateReader(strings.NewReader(instance))
+	assert.NoError(t, err)
+	assert.Equal(t, expected, messages(result))
+}
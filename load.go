@@ -0,0 +1,154 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// LoadOptions configures how schemas are loaded from files.
+type LoadOptions struct {
+	// How to parse the loaded schemas.
+	RegistryOptions
+
+	// IDBase, if non-nil, gives each schema without an "id" a default one, by
+	// resolving the slash-separated path of its file against IDBase. For
+	// example, with an IDBase of "http://example.com/schemas/", a file at
+	// "users/user.json" gets the id "http://example.com/schemas/users/user.json".
+	IDBase *url.URL
+}
+
+// LoadError is a problem with one of the files passed to a loader: either an
+// error reading or decoding it, or a SchemaError in the schema it contains.
+type LoadError struct {
+	Path string
+	Err  error
+}
+
+func (e LoadError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// Cause returns the underlying error. It satisfies the interface used by
+// github.com/pkg/errors.Cause.
+func (e LoadError) Cause() error {
+	return e.Err
+}
+
+// LoadErrors is every problem with the files passed to a loader.
+type LoadErrors []LoadError
+
+func (e LoadErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "\n")
+}
+
+// LoadFS constructs a registry from every file in fsys whose name ends in
+// ".json", walking the whole of fsys in lexical order. Paths in errors, and
+// those used to make default IDs, are relative to the root of fsys.
+//
+// If any of the files cannot be read or decoded, or contain invalid schemas,
+// the returned error is LoadErrors. Otherwise, errors are as for
+// NewRegistryWithOptions.
+func LoadFS(fsys fs.FS, opts LoadOptions) (Registry, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !d.IsDir() && strings.HasSuffix(p, ".json") {
+			paths = append(paths, p)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return Registry{}, err
+	}
+
+	return load(paths, func(p string) ([]byte, error) {
+		return fs.ReadFile(fsys, p)
+	}, opts)
+}
+
+// LoadDir is like LoadFS, but loads from the directory tree rooted at dir.
+func LoadDir(dir string, opts LoadOptions) (Registry, error) {
+	return LoadFS(os.DirFS(dir), opts)
+}
+
+// LoadGlob constructs a registry from every file matching pattern, using the
+// syntax of filepath.Match. Otherwise, it is like LoadFiles.
+func LoadGlob(pattern string, opts LoadOptions) (Registry, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	return LoadFiles(paths, opts)
+}
+
+// LoadFiles constructs a registry from the files at paths. Paths in errors are
+// as given, and default IDs are made from paths converted to use slashes.
+// Otherwise, it is like LoadFS.
+func LoadFiles(paths []string, opts LoadOptions) (Registry, error) {
+	return load(paths, os.ReadFile, opts)
+}
+
+// load constructs a registry from the files at paths, reading each one with
+// readFile.
+func load(paths []string, readFile func(string) ([]byte, error), opts LoadOptions) (Registry, error) {
+	// Every file is read, so that all of the problems with them are reported at
+	// once.
+	var loadErrors LoadErrors
+	schemas := make([]SchemaStruct, len(paths))
+	positions := make([]Positions, len(paths))
+	for i, p := range paths {
+		data, err := readFile(p)
+		if err != nil {
+			loadErrors = append(loadErrors, LoadError{Path: p, Err: err})
+			continue
+		}
+
+		if err := json.Unmarshal(data, &schemas[i]); err != nil {
+			loadErrors = append(loadErrors, LoadError{Path: p, Err: err})
+			continue
+		}
+
+		if _, positions[i], err = DecodeWithPositions(data); err != nil {
+			loadErrors = append(loadErrors, LoadError{Path: p, Err: err})
+			continue
+		}
+
+		if schemas[i].ID == nil && opts.IDBase != nil {
+			id := opts.IDBase.ResolveReference(&url.URL{Path: path.Clean(filepath.ToSlash(p))}).String()
+			schemas[i].ID = &id
+		}
+	}
+
+	if len(loadErrors) > 0 {
+		return Registry{}, loadErrors
+	}
+
+	registry, err := NewRegistryWithOptions(schemas, opts.RegistryOptions)
+	if schemaErrors, ok := err.(SchemaErrors); ok {
+		schemaErrors.AddPositions(positions)
+		for _, schemaErr := range schemaErrors {
+			loadErrors = append(loadErrors, LoadError{Path: paths[schemaErr.Index], Err: schemaErr})
+		}
+
+		return Registry{}, loadErrors
+	}
+
+	return registry, err
+}
@@ -0,0 +1,110 @@
+package jsonvalidate
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/json-validate/json-pointer-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root.json":         {Data: []byte(`{"properties":{"user":{"ref":"users/user.json"}}}`)},
+		"users/user.json":   {Data: []byte(`{"properties":{"name":{"ref":"name.json"}}}`)},
+		"users/name.json":   {Data: []byte(`{"type":"string"}`)},
+		"users/README.md":   {Data: []byte(`not a schema`)},
+		"other/custom.json": {Data: []byte(`{"id":"http://example.com/custom"}`)},
+	}
+
+	_, err := LoadFS(fsys, LoadOptions{})
+	assert.Equal(t, LoadErrors{
+		LoadError{
+			Path: "users/name.json",
+			Err: SchemaError{
+				Index:    2,
+				Ptr:      jsonpointer.Ptr{Tokens: []string{}},
+				Keyword:  "id",
+				Code:     SchemaErrorCodeDuplicateID,
+				Err:      ErrDuplicateID{FirstIndex: 1},
+				Position: &Position{Line: 1, Column: 1},
+			},
+		},
+		LoadError{
+			Path: "users/user.json",
+			Err: SchemaError{
+				Index:    3,
+				Ptr:      jsonpointer.Ptr{Tokens: []string{}},
+				Keyword:  "id",
+				Code:     SchemaErrorCodeDuplicateID,
+				Err:      ErrDuplicateID{FirstIndex: 1},
+				Position: &Position{Line: 1, Column: 1},
+			},
+		},
+	}, err)
+
+	base, err := url.Parse("http://example.com/schemas/")
+	assert.NoError(t, err)
+
+	registry, err := LoadFS(fsys, LoadOptions{IDBase: base})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(registry.Schemas))
+
+	uri, err := url.Parse("http://example.com/schemas/root.json")
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+	result, err := validator.ValidateURI(*uri, map[string]interface{}{
+		"user": map[string]interface{}{"name": 3.0},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "http://example.com/schemas/users/name.json", result.Errors[0].SchemaURI.String())
+}
+
+func TestLoadFSErrors(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.json": {Data: []byte(`{"type":`)},
+		"b.json": {Data: []byte(`{"id":"http://example.com/b",
+  "type": "foo"}`)},
+		"c.json": {Data: []byte(`{"id":"http://example.com/c","elements":[]}`)},
+	}
+
+	_, err := LoadFS(fsys, LoadOptions{})
+	loadErrors, ok := err.(LoadErrors)
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(loadErrors))
+	assert.Equal(t, "a.json", loadErrors[0].Path)
+	assert.Equal(t, "c.json", loadErrors[1].Path)
+
+	delete(fsys, "a.json")
+	delete(fsys, "c.json")
+	_, err = LoadFS(fsys, LoadOptions{})
+	assert.Equal(t, `b.json: schema at index 0 (id: http://example.com/b): "": type: invalid type: foo (line 2, column 11)`, err.Error())
+}
+
+func TestLoadGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonvalidate")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.json"), []byte(`{"ref":"http://example.com/b"}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"id":"http://example.com/b"}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.txt"), []byte(`{"type":"foo"}`), 0644))
+
+	registry, err := LoadGlob(filepath.Join(dir, "*.json"), LoadOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(registry.Schemas))
+
+	registry, err = LoadDir(dir, LoadOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(registry.Schemas))
+
+	_, err = LoadFiles([]string{filepath.Join(dir, "missing.json")}, LoadOptions{})
+	assert.Equal(t, 1, len(err.(LoadErrors)))
+	assert.True(t, os.IsNotExist(err.(LoadErrors)[0].Err))
+}
@@ -0,0 +1,196 @@
+package jsonvalidate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/json-validate/json-pointer-go"
+)
+
+// Position is a location within a JSON document.
+type Position struct {
+	// The number of bytes before the location, starting from zero.
+	Offset int `json:"offset"`
+
+	// The line the location is on, starting from one.
+	Line int `json:"line"`
+
+	// The number of bytes into the line the location is, starting from one.
+	Column int `json:"column"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %d, column %d", p.Line, p.Column)
+}
+
+// Positions maps JSON Pointers to the positions of the values they refer to.
+// The keys are the string form of the pointers.
+type Positions map[string]Position
+
+// Lookup returns the position of the value ptr refers to.
+func (p Positions) Lookup(ptr jsonpointer.Ptr) (Position, bool) {
+	pos, ok := p[ptr.String()]
+	return pos, ok
+}
+
+// PositionDecoder decodes JSON values like json.Decoder does, additionally
+// recording where in its input each part of each value begins.
+//
+// Because positions are relative to the start of the input, PositionDecoder
+// works from the input in its entirety rather than from an io.Reader.
+type PositionDecoder struct {
+	data       []byte
+	decoder    *json.Decoder
+	lineStarts []int // the offset of the first byte of each line
+}
+
+// NewPositionDecoder constructs a PositionDecoder which reads from data.
+func NewPositionDecoder(data []byte) *PositionDecoder {
+	lineStarts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	return &PositionDecoder{
+		data:       data,
+		decoder:    json.NewDecoder(bytes.NewReader(data)),
+		lineStarts: lineStarts,
+	}
+}
+
+// DecodeWithPositions decodes the JSON value in data, producing the same value
+// json.Unmarshal would when decoding into an interface{}, along with the
+// position of every value within it.
+func DecodeWithPositions(data []byte) (interface{}, Positions, error) {
+	return NewPositionDecoder(data).Decode()
+}
+
+// Decode reads the next JSON value from the decoder's input. If there are no
+// more values, the returned error is io.EOF.
+func (d *PositionDecoder) Decode() (interface{}, Positions, error) {
+	positions := Positions{}
+	value, err := d.decode([]string{}, positions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return value, positions, nil
+}
+
+func (d *PositionDecoder) decode(tokens []string, positions Positions) (interface{}, error) {
+	start := d.nextOffset()
+	token, err := d.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	positions[jsonpointer.Ptr{Tokens: tokens}.String()] = d.position(start)
+
+	// Sub-values get their own copy of tokens to append to.
+	tokens = tokens[:len(tokens):len(tokens)]
+
+	switch token {
+	case json.Delim('['):
+		elems := []interface{}{}
+		for d.decoder.More() {
+			elem, err := d.decode(append(tokens, strconv.Itoa(len(elems))), positions)
+			if err != nil {
+				return nil, err
+			}
+
+			elems = append(elems, elem)
+		}
+
+		_, err := d.decoder.Token()
+		return elems, err
+	case json.Delim('{'):
+		object := map[string]interface{}{}
+		for d.decoder.More() {
+			key, err := d.decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := d.decode(append(tokens, key.(string)), positions)
+			if err != nil {
+				return nil, err
+			}
+
+			object[key.(string)] = value
+		}
+
+		_, err := d.decoder.Token()
+		return object, err
+	}
+
+	return token, nil
+}
+
+// nextOffset returns the offset at which the decoder's next token begins. The
+// decoder's own offset is just past the previous token, which may be followed
+// by whitespace and separators.
+func (d *PositionDecoder) nextOffset() int {
+	offset := int(d.decoder.InputOffset())
+	for offset < len(d.data) {
+		switch d.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+
+	return offset
+}
+
+func (d *PositionDecoder) position(offset int) Position {
+	line := sort.Search(len(d.lineStarts), func(i int) bool {
+		return d.lineStarts[i] > offset
+	})
+
+	return Position{
+		Offset: offset,
+		Line:   line,
+		Column: offset - d.lineStarts[line-1] + 1,
+	}
+}
+
+// AddPositions sets the Position of each of the errors in r, using positions
+// obtained from decoding the instance which was validated.
+func (r *ValidationResult) AddPositions(positions Positions) {
+	for i := range r.Errors {
+		if pos, ok := positions.Lookup(r.Errors[i].InstancePath); ok {
+			r.Errors[i].Position = &pos
+		}
+	}
+}
+
+// AddPositions sets the Position of each of the errors in e. positions holds,
+// for each schema passed to NewRegistry, the positions obtained from decoding
+// that schema.
+func (e SchemaErrors) AddPositions(positions []Positions) {
+	for i, err := range e {
+		if err.Index >= len(positions) {
+			continue
+		}
+
+		// Prefer the position of the keyword's value, falling back to that of
+		// the schema which contains it.
+		tokens := make([]string, len(err.Ptr.Tokens), len(err.Ptr.Tokens)+1)
+		copy(tokens, err.Ptr.Tokens)
+
+		pos, ok := positions[err.Index].Lookup(jsonpointer.Ptr{Tokens: append(tokens, err.Keyword)})
+		if !ok {
+			pos, ok = positions[err.Index].Lookup(err.Ptr)
+		}
+
+		if ok {
+			e[i].Position = &pos
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/json-validate/json-pointer-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeWithPositions(t *testing.T) {
+	data := "{\n  \"a\": [1, \"x\"],\n\t\"b\" : {\"c\":null}\n}\n[true]"
+	decoder := NewPositionDecoder([]byte(data))
+
+	value, positions, err := decoder.Decode()
+	assert.NoError(t, err)
+
+	var expected interface{}
+	assert.NoError(t, json.Unmarshal([]byte(data[:len(data)-7]), &expected))
+	assert.Equal(t, expected, value)
+	assert.Equal(t, Positions{
+		"":     Position{Offset: 0, Line: 1, Column: 1},
+		"/a":   Position{Offset: 9, Line: 2, Column: 8},
+		"/a/0": Position{Offset: 10, Line: 2, Column: 9},
+		"/a/1": Position{Offset: 13, Line: 2, Column: 12},
+		"/b":   Position{Offset: 26, Line: 3, Column: 8},
+		"/b/c": Position{Offset: 31, Line: 3, Column: 13},
+	}, positions)
+
+	value, positions, err = decoder.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{true}, value)
+	assert.Equal(t, Positions{
+		"":   Position{Offset: 39, Line: 5, Column: 1},
+		"/0": Position{Offset: 40, Line: 5, Column: 2},
+	}, positions)
+
+	_, _, err = decoder.Decode()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestValidationResultAddPositions(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{"elements":{"type":"string"}}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	instance, positions, err := DecodeWithPositions([]byte("[\n  \"a\",\n  1\n]"))
+	assert.NoError(t, err)
+
+	result, err := Validator{Registry: registry}.Validate(instance)
+	assert.NoError(t, err)
+
+	result.AddPositions(positions)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, &Position{Offset: 11, Line: 3, Column: 3}, result.Errors[0].Position)
+}
+
+func TestSchemaErrorsAddPositions(t *testing.T) {
+	data := []byte("{\n  \"properties\": {\n    \"a\": {\"type\": \"foo\"}\n  }\n}")
+
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal(data, &schema))
+
+	_, positions, err := DecodeWithPositions(data)
+	assert.NoError(t, err)
+
+	_, err = NewRegistry([]SchemaStruct{schema})
+	schemaErrors, ok := err.(SchemaErrors)
+	assert.True(t, ok)
+
+	schemaErrors.AddPositions([]Positions{positions})
+	assert.Equal(t, SchemaErrors{
+		SchemaError{
+			Ptr:      jsonpointer.Ptr{Tokens: []string{"properties", "a"}},
+			Keyword:  "type",
+			Code:     SchemaErrorCodeInvalidType,
+			Err:      schemaErrors[0].Err,
+			Position: &Position{Offset: 38, Line: 3, Column: 19},
+		},
+	}, schemaErrors)
+
+	assert.Contains(t, schemaErrors.Error(), "(line 3, column 19)")
+}
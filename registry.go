@@ -3,13 +3,63 @@ package jsonvalidate
 import (
 	"fmt"
 	"net/url"
+	"sort"
 
-	"github.com/pkg/errors"
+	"github.com/json-validate/json-pointer-go"
 )
 
 // Registry is a collection of schemas which may refer to each other.
 type Registry struct {
 	Schemas map[url.URL]*Schema
+
+	opts RegistryOptions // used to parse schemas passed to Add and Replace
+}
+
+// RegistryOptions configures how NewRegistryWithOptions parses schemas.
+type RegistryOptions struct {
+	// Strict, if true, makes it an error for a schema, or its "discriminator",
+	// to contain a keyword which is not part of the spec. Metadata keywords,
+	// which are those in MetadataKeywords or AllowedKeywords, are exempt.
+	Strict bool
+
+	// AllowedKeywords lists additional keywords which are permitted in strict
+	// mode.
+	AllowedKeywords []string
+
+	// ReplaceDuplicateIDs, if true, makes a schema replace any earlier schema
+	// with the same "id". Otherwise, it is an error for two schemas to have the
+	// same "id", including for two schemas to both have no "id".
+	ReplaceDuplicateIDs bool
+
+	// Resolver, if non-nil, is asked for each schema which is referred to but
+	// wasn't passed to NewRegistryWithOptions. Schemas it returns may refer to
+	// yet more schemas, which are resolved in turn.
+	Resolver SchemaResolver
+
+	// MaxResolved is how many schemas may be fetched from Resolver. If it is
+	// zero, DefaultMaxResolved is used; Unlimited disables the limit.
+	MaxResolved int
+}
+
+// MetadataKeywords are the keywords which are permitted in strict mode even
+// though they aren't part of the spec, because they only describe a schema and
+// don't affect validation.
+var MetadataKeywords = []string{"title", "description", ErrorMessageKeyword}
+
+func (o RegistryOptions) allowsKeyword(keyword string) bool {
+	for _, k := range MetadataKeywords {
+		if k == keyword {
+			return true
+		}
+	}
+
+	for _, k := range o.AllowedKeywords {
+		if k == keyword {
+			return true
+		}
+	}
+
+	return false
 }
 
 // NewRegistry constructs a new registry from a set of schemas.
@@ -19,15 +69,40 @@ type Registry struct {
 // create new pointers into the registry's schemas, then discarding the registry
 // will allow all of the contained schemas to be garbage collected.
 func NewRegistry(schemaStructs []SchemaStruct) (Registry, error) {
+	return NewRegistryWithOptions(schemaStructs, RegistryOptions{})
+}
+
+// NewRegistryWithOptions is like NewRegistry, but allows for configuring how
+// schemas are parsed.
+func NewRegistryWithOptions(schemaStructs []SchemaStruct, opts RegistryOptions) (Registry, error) {
 	// In a first pass, ensure that all schemas are structurally valid.
 	schemas := map[url.URL]*Schema{}
+	indices := map[url.URL]int{} // index of the schema with each ID
+	var schemaErrors SchemaErrors
 	for i, schema := range schemaStructs {
-		s, err := parseSchemaStruct(true, schema)
-		if err != nil {
-			return Registry{}, errors.Wrapf(err, "error parsing schema at index %d", i)
+		p := schemaParser{opts: opts, index: i}
+		if schema.ID != nil {
+			p.id = *schema.ID
+		}
+
+		s := p.parse([]string{}, true, schema)
+		if len(p.errors) > 0 {
+			schemaErrors = append(schemaErrors, p.errors...)
+			continue
+		}
+
+		if first, ok := indices[*s.ID]; ok && !opts.ReplaceDuplicateIDs {
+			p.report([]string{}, "id", SchemaErrorCodeDuplicateID, ErrDuplicateID{ID: *s.ID, FirstIndex: first})
+			schemaErrors = append(schemaErrors, p.errors...)
+			continue
 		}
 
 		schemas[*s.ID] = &s
+		indices[*s.ID] = i
+	}
+
+	if len(schemaErrors) > 0 {
+		return Registry{}, schemaErrors
 	}
 
 	// In a second pass, ensure that all references are valid, and compute their
@@ -37,25 +112,115 @@ func NewRegistry(schemaStructs []SchemaStruct) (Registry, error) {
 		populateSchemaRefs(&missingURIs, schemas, schema.ID, schema)
 	}
 
+	if len(missingURIs) > 0 && opts.Resolver != nil {
+		var err error
+		if missingURIs, err = resolveSchemas(schemas, schemas, missingURIs, opts); err != nil {
+			return Registry{}, err
+		}
+	}
+
 	if len(missingURIs) > 0 {
-		return Registry{}, ErrMissingSchemas{URIs: missingURIs}
+		return Registry{}, missingSchemas(missingURIs)
 	}
 
-	return Registry{Schemas: schemas}, nil
+	// Finally, ensure that validation always terminates.
+	if cycle := findRefCycle(schemas); cycle != nil {
+		return Registry{}, ErrRefCycle{URIs: cycle}
+	}
+
+	return Registry{Schemas: schemas, opts: opts}, nil
+}
+
+// schemaParser turns SchemaStructs into Schemas, collecting every error it
+// comes across along the way.
+type schemaParser struct {
+	opts   RegistryOptions
+	index  int    // the index of the root schema being parsed
+	id     string // the "id" of the root schema being parsed
+	errors []SchemaError
 }
 
-func parseSchemaStruct(root bool, s SchemaStruct) (Schema, error) {
+func (p *schemaParser) report(tokens []string, keyword string, code SchemaErrorCode, err error) {
+	ptr := make([]string, len(tokens))
+	copy(ptr, tokens)
+
+	p.errors = append(p.errors, SchemaError{
+		Index:   p.index,
+		ID:      p.id,
+		Ptr:     jsonpointer.Ptr{Tokens: ptr},
+		Keyword: keyword,
+		Code:    code,
+		Err:     err,
+	})
+}
+
+// setKind records that keyword makes out a schema of the given kind.
+func (p *schemaParser) setKind(out *Schema, tokens []string, keyword string, kind SchemaKind) {
+	// "properties" and "optionalProperties" are the only keywords which may
+	// appear together.
+	if out.Kind == SchemaKindEmpty || (out.Kind == SchemaKindProperties && kind == SchemaKindProperties) {
+		out.Kind = kind
+	} else {
+		p.report(tokens, keyword, SchemaErrorCodeBadKind, ErrBadSchemaKind)
+	}
+}
+
+// parseMap parses each of the schemas in m, in order of their keys.
+func (p *schemaParser) parseMap(tokens []string, keyword string, m map[string]SchemaStruct) map[string]*Schema {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	out := make(map[string]*Schema, len(m))
+	for _, k := range keys {
+		schema := p.parse(append(tokens, keyword, k), false, m[k])
+		out[k] = &schema
+	}
+
+	return out
+}
+
+// checkKeywords reports each of extra, the keywords in the object at tokens
+// which aren't part of the spec, if it isn't allowed in strict mode.
+func (p *schemaParser) checkKeywords(tokens []string, extra map[string]interface{}) {
+	if !p.opts.Strict {
+		return
+	}
+
+	keywords := make([]string, 0, len(extra))
+	for k := range extra {
+		keywords = append(keywords, k)
+	}
+
+	// Sort, so that the same schema always produces the same errors.
+	sort.Strings(keywords)
+	for _, k := range keywords {
+		if !p.opts.allowsKeyword(k) {
+			p.report(tokens, k, SchemaErrorCodeUnknownKeyword, ErrUnknownKeyword{Keyword: k})
+		}
+	}
+}
+
+func (p *schemaParser) parse(tokens []string, root bool, s SchemaStruct) Schema {
+	// Sub-schemas get their own copy of tokens to append to.
+	tokens = tokens[:len(tokens):len(tokens)]
+
 	out := Schema{}
 	out.IsRoot = root
 
+	p.checkKeywords(tokens, s.Extra)
+
 	if s.ID != nil {
 		if !root {
-			return Schema{}, ErrBadSubSchema
+			p.report(tokens, "id", SchemaErrorCodeBadSubSchema, ErrBadSubSchema)
 		}
 
 		id, err := url.Parse(*s.ID)
 		if err != nil {
-			return Schema{}, err
+			p.report(tokens, "id", SchemaErrorCodeInvalidURI, err)
 		}
 
 		out.ID = id
@@ -69,43 +234,28 @@ func parseSchemaStruct(root bool, s SchemaStruct) (Schema, error) {
 
 	if s.Definitions != nil {
 		if !root {
-			return Schema{}, ErrBadSubSchema
+			p.report(tokens, "definitions", SchemaErrorCodeBadSubSchema, ErrBadSubSchema)
 		}
 
-		out.Definitions = make(map[string]*Schema, len(*s.Definitions))
-		for k, v := range *s.Definitions {
-			schema, err := parseSchemaStruct(false, v)
-			if err != nil {
-				return Schema{}, errors.Wrapf(err, "error parsing definition %s", k)
-			}
-
-			out.Definitions[k] = &schema
-		}
+		out.Definitions = p.parseMap(tokens, "definitions", *s.Definitions)
 	}
 
 	out.Kind = SchemaKindEmpty
+	out.Nullable = s.Nullable != nil && *s.Nullable
 
 	if s.Ref != nil {
-		if out.Kind == SchemaKindEmpty {
-			out.Kind = SchemaKindRef
-		} else {
-			return Schema{}, ErrBadSchemaKind
-		}
+		p.setKind(&out, tokens, "ref", SchemaKindRef)
 
 		ref, err := url.Parse(*s.Ref)
 		if err != nil {
-			return Schema{}, err
+			p.report(tokens, "ref", SchemaErrorCodeInvalidURI, err)
 		}
 
 		out.Ref = ref
 	}
 
 	if s.Type != nil {
-		if out.Kind == SchemaKindEmpty {
-			out.Kind = SchemaKindType
-		} else {
-			return Schema{}, ErrBadSchemaKind
-		}
+		p.setKind(&out, tokens, "type", SchemaKindType)
 
 		switch *s.Type {
 		case "null":
@@ -116,98 +266,135 @@ func parseSchemaStruct(root bool, s SchemaStruct) (Schema, error) {
 			out.Type = SchemaTypeNumber
 		case "string":
 			out.Type = SchemaTypeString
+		case "int8":
+			out.Type = SchemaTypeInt8
+		case "uint8":
+			out.Type = SchemaTypeUint8
+		case "int16":
+			out.Type = SchemaTypeInt16
+		case "uint16":
+			out.Type = SchemaTypeUint16
+		case "int32":
+			out.Type = SchemaTypeInt32
+		case "uint32":
+			out.Type = SchemaTypeUint32
+		case "float32":
+			out.Type = SchemaTypeFloat32
+		case "float64":
+			out.Type = SchemaTypeFloat64
+		case "timestamp":
+			out.Type = SchemaTypeTimestamp
 		default:
-			return Schema{}, fmt.Errorf("invalid type: %s", *s.Type)
+			p.report(tokens, "type", SchemaErrorCodeInvalidType, fmt.Errorf("invalid type: %s", *s.Type))
 		}
 	}
 
-	if s.Elements != nil {
-		if out.Kind == SchemaKindEmpty {
-			out.Kind = SchemaKindElements
-		} else {
-			return Schema{}, ErrBadSchemaKind
+	if s.Enum != nil {
+		p.setKind(&out, tokens, "enum", SchemaKindEnum)
+
+		if len(*s.Enum) == 0 {
+			p.report(tokens, "enum", SchemaErrorCodeInvalidEnum, fmt.Errorf("empty enum"))
 		}
 
-		schema, err := parseSchemaStruct(false, *s.Elements)
-		if err != nil {
-			return Schema{}, errors.Wrap(err, "error parsing elements")
+		out.Enum = make(map[string]struct{}, len(*s.Enum))
+		for _, v := range *s.Enum {
+			if _, ok := out.Enum[v]; ok {
+				p.report(tokens, "enum", SchemaErrorCodeInvalidEnum, fmt.Errorf("duplicate enum value: %s", v))
+			}
+
+			out.Enum[v] = struct{}{}
 		}
+	}
+
+	if s.Elements != nil {
+		p.setKind(&out, tokens, "elements", SchemaKindElements)
 
+		schema := p.parse(append(tokens, "elements"), false, *s.Elements)
 		out.Elements = &schema
 	}
 
 	if s.Properties != nil {
-		if out.Kind == SchemaKindEmpty {
-			out.Kind = SchemaKindProperties
-		} else {
-			return Schema{}, ErrBadSchemaKind
-		}
-
-		out.Properties = make(map[string]*Schema, len(*s.Properties))
-		for k, v := range *s.Properties {
-			schema, err := parseSchemaStruct(false, v)
-			if err != nil {
-				return Schema{}, errors.Wrapf(err, "error parsing properties %s", k)
-			}
-
-			out.Properties[k] = &schema
-		}
+		p.setKind(&out, tokens, "properties", SchemaKindProperties)
+		out.Properties = p.parseMap(tokens, "properties", *s.Properties)
 	}
 
 	if s.OptionalProperties != nil {
-		if out.Kind == SchemaKindEmpty || out.Kind == SchemaKindProperties {
-			out.Kind = SchemaKindProperties
-		} else {
-			return Schema{}, ErrBadSchemaKind
-		}
-
-		out.OptionalProperties = make(map[string]*Schema, len(*s.OptionalProperties))
-		for k, v := range *s.OptionalProperties {
-			schema, err := parseSchemaStruct(false, v)
-			if err != nil {
-				return Schema{}, errors.Wrapf(err, "error parsing optionalProperties %s", k)
-			}
+		p.setKind(&out, tokens, "optionalProperties", SchemaKindProperties)
+		out.OptionalProperties = p.parseMap(tokens, "optionalProperties", *s.OptionalProperties)
+	}
 
-			out.OptionalProperties[k] = &schema
+	if s.AdditionalProperties != nil {
+		if out.Kind != SchemaKindProperties {
+			p.report(tokens, "additionalProperties", SchemaErrorCodeBadKind, ErrBadSchemaKind)
 		}
+
+		additionalProperties := *s.AdditionalProperties
+		out.AdditionalProperties = &additionalProperties
 	}
 
 	if s.Values != nil {
-		if out.Kind == SchemaKindEmpty {
-			out.Kind = SchemaKindValues
-		} else {
-			return Schema{}, ErrBadSchemaKind
-		}
-
-		schema, err := parseSchemaStruct(false, *s.Values)
-		if err != nil {
-			return Schema{}, errors.Wrap(err, "error parsing values")
-		}
+		p.setKind(&out, tokens, "values", SchemaKindValues)
 
+		schema := p.parse(append(tokens, "values"), false, *s.Values)
 		out.Values = &schema
 	}
 
 	if s.Discriminator != nil {
-		if out.Kind == SchemaKindEmpty {
-			out.Kind = SchemaKindDiscriminator
-		} else {
-			return Schema{}, ErrBadSchemaKind
-		}
+		p.setKind(&out, tokens, "discriminator", SchemaKindDiscriminator)
+		p.checkKeywords(append(tokens, "discriminator"), s.Discriminator.Extra)
 
 		out.DiscriminatorPropertyName = s.Discriminator.PropertyName
-		out.DiscriminatorMapping = make(map[string]*Schema, len(s.Discriminator.Mapping))
-		for k, v := range s.Discriminator.Mapping {
-			schema, err := parseSchemaStruct(false, v)
-			if err != nil {
-				return Schema{}, errors.Wrapf(err, "error parsing mapping %s", k)
+		out.DiscriminatorMapping = p.parseMap(append(tokens, "discriminator"), "mapping", s.Discriminator.Mapping)
+
+		name := out.DiscriminatorPropertyName
+		if name == "" {
+			p.report(append(tokens, "discriminator"), "propertyName", SchemaErrorCodeInvalidDiscriminator, fmt.Errorf("empty discriminator property name"))
+		}
+
+		// Each mapping value describes the rest of the object, so it has to be
+		// about properties, and can't say anything about the discriminator's
+		// own property.
+		mappingTokens := append(tokens, "discriminator", "mapping")
+		for _, k := range sortedSchemaKeys(out.DiscriminatorMapping) {
+			mapping := out.DiscriminatorMapping[k]
+			if mapping.Kind != SchemaKindProperties {
+				p.report(mappingTokens, k, SchemaErrorCodeInvalidDiscriminator, fmt.Errorf("mapping value must be a properties schema"))
+				continue
+			}
+
+			redeclared := fmt.Errorf("mapping value redeclares discriminator property: %s", name)
+			if _, ok := mapping.Properties[name]; ok {
+				p.report(append(mappingTokens, k, "properties"), name, SchemaErrorCodeInvalidDiscriminator, redeclared)
 			}
 
-			out.DiscriminatorMapping[k] = &schema
+			if _, ok := mapping.OptionalProperties[name]; ok {
+				p.report(append(mappingTokens, k, "optionalProperties"), name, SchemaErrorCodeInvalidDiscriminator, redeclared)
+			}
 		}
 	}
 
 	out.Extra = s.Extra
-	return out, nil
+	return out
+}
+
+// missingSchemas returns ErrMissingSchemas for uris, which may contain
+// duplicates. The URIs are deduplicated and sorted, so that the same schemas
+// always produce the same error.
+func missingSchemas(uris []url.URL) ErrMissingSchemas {
+	seen := make(map[url.URL]bool, len(uris))
+	out := make([]url.URL, 0, len(uris))
+	for _, uri := range uris {
+		if !seen[uri] {
+			seen[uri] = true
+			out = append(out, uri)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+
+	return ErrMissingSchemas{URIs: out}
 }
 
 func populateSchemaRefs(missing *[]url.URL, registry map[url.URL]*Schema, base *url.URL, schema *Schema) {
@@ -258,3 +445,119 @@ func populateSchemaRefs(missing *[]url.URL, registry map[url.URL]*Schema, base *
 		populateSchemaRefs(missing, registry, base, subSchema)
 	}
 }
+
+// findRefCycle returns the refs making up a cycle of schemas which evaluate one
+// another against the same part of an instance, or nil if there is no such
+// cycle. Validating against a schema in the cycle would never terminate,
+// because it never consumes any of the instance.
+//
+// Schemas evaluate a sub-schema against the same part of an instance through
+// "ref", and through the schemas in a discriminator's "mapping". Because the
+// latter are always "properties" schemas, only refs can form a cycle.
+func findRefCycle(schemas map[url.URL]*Schema) []url.URL {
+	ids := make([]url.URL, 0, len(schemas))
+	for id := range schemas {
+		ids = append(ids, id)
+	}
+
+	// Sort, so that the same schemas always produce the same error.
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+
+	f := refCycleFinder{visited: map[*Schema]bool{}, onPath: map[*Schema]int{}}
+	for _, id := range ids {
+		if cycle := f.walk(schemas[id]); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// refCycleFinder does a depth-first search over the refs between schemas.
+type refCycleFinder struct {
+	visited map[*Schema]bool // schemas whose search has completed
+	onPath  map[*Schema]int  // index within path of schemas being searched
+	path    []*Schema
+}
+
+// walk searches from schema and each of its sub-schemas.
+func (f *refCycleFinder) walk(schema *Schema) []url.URL {
+	if cycle := f.search(schema); cycle != nil {
+		return cycle
+	}
+
+	for _, subSchema := range subSchemas(schema) {
+		if cycle := f.walk(subSchema); cycle != nil {
+			return cycle
+		}
+	}
+
+	return nil
+}
+
+// search follows the chain of refs starting at schema, and returns the refs of
+// the cycle it comes across, if any.
+func (f *refCycleFinder) search(schema *Schema) []url.URL {
+	if f.visited[schema] {
+		return nil
+	}
+
+	if i, ok := f.onPath[schema]; ok {
+		var cycle []url.URL
+		for _, s := range f.path[i:] {
+			if s.RefSchema != nil {
+				cycle = append(cycle, *s.Base.ResolveReference(s.Ref))
+			}
+		}
+
+		return cycle
+	}
+
+	f.onPath[schema] = len(f.path)
+	f.path = append(f.path, schema)
+
+	if schema.RefSchema != nil {
+		if cycle := f.search(schema.RefSchema); cycle != nil {
+			return cycle
+		}
+	}
+
+	delete(f.onPath, schema)
+	f.path = f.path[:len(f.path)-1]
+	f.visited[schema] = true
+
+	return nil
+}
+
+// subSchemas returns the schemas directly within schema, in a deterministic
+// order.
+func subSchemas(schema *Schema) []*Schema {
+	out := []*Schema{}
+	for _, k := range sortedSchemaKeys(schema.Definitions) {
+		out = append(out, schema.Definitions[k])
+	}
+
+	if schema.Elements != nil {
+		out = append(out, schema.Elements)
+	}
+
+	for _, k := range sortedSchemaKeys(schema.Properties) {
+		out = append(out, schema.Properties[k])
+	}
+
+	for _, k := range sortedSchemaKeys(schema.OptionalProperties) {
+		out = append(out, schema.OptionalProperties[k])
+	}
+
+	if schema.Values != nil {
+		out = append(out, schema.Values)
+	}
+
+	for _, k := range sortedSchemaKeys(schema.DiscriminatorMapping) {
+		out = append(out, schema.DiscriminatorMapping[k])
+	}
+
+	return out
+}
@@ -1,11 +1,13 @@
 package jsonvalidate
 
 import (
+	"encoding/json"
 	e "errors"
 	"net/url"
 	"strconv"
 	"testing"
 
+	"github.com/json-validate/json-pointer-go"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
@@ -94,6 +96,7 @@ func TestNewRegistry(t *testing.T) {
 	badURI := "::"
 	emptyURI := ""
 	typeNull := "null"
+	boolFalse := false
 	undefinedURI1 := "http://example.com/foo"
 	undefinedURI2 := "http://example.com/bar"
 
@@ -199,7 +202,9 @@ func TestNewRegistry(t *testing.T) {
 					Discriminator: &SchemaStructDiscriminator{
 						PropertyName: "::",
 						Mapping: map[string]SchemaStruct{
-							"a": SchemaStruct{},
+							"a": SchemaStruct{
+								Properties: &map[string]SchemaStruct{},
+							},
 						},
 					},
 				},
@@ -212,7 +217,10 @@ func TestNewRegistry(t *testing.T) {
 						Kind:   SchemaKindDiscriminator,
 						DiscriminatorPropertyName: "::",
 						DiscriminatorMapping: map[string]*Schema{
-							"a": &Schema{Kind: SchemaKindEmpty},
+							"a": &Schema{
+								Kind:       SchemaKindProperties,
+								Properties: map[string]*Schema{},
+							},
 						},
 					},
 				},
@@ -297,6 +305,24 @@ func TestNewRegistry(t *testing.T) {
 			Registry{},
 			e.New("invalid type: ::"),
 		},
+		{
+			[]SchemaStruct{
+				SchemaStruct{
+					Enum: &[]string{},
+				},
+			},
+			Registry{},
+			e.New("empty enum"),
+		},
+		{
+			[]SchemaStruct{
+				SchemaStruct{
+					Enum: &[]string{"a", "b", "a"},
+				},
+			},
+			Registry{},
+			e.New("duplicate enum value: a"),
+		},
 		{
 			[]SchemaStruct{
 				SchemaStruct{
@@ -539,6 +565,26 @@ func TestNewRegistry(t *testing.T) {
 			Registry{},
 			ErrBadSchemaKind,
 		},
+		{
+			[]SchemaStruct{
+				SchemaStruct{
+					Type: &typeNull,
+					Enum: &[]string{"a"},
+				},
+			},
+			Registry{},
+			ErrBadSchemaKind,
+		},
+		{
+			[]SchemaStruct{
+				SchemaStruct{
+					Values:               &SchemaStruct{},
+					AdditionalProperties: &boolFalse,
+				},
+			},
+			Registry{},
+			ErrBadSchemaKind,
+		},
 		{
 			[]SchemaStruct{
 				SchemaStruct{
@@ -585,8 +631,8 @@ func TestNewRegistry(t *testing.T) {
 			Registry{},
 			ErrMissingSchemas{
 				URIs: []url.URL{
-					url.URL{Scheme: "http", Host: "example.com", Path: "/foo"},
 					url.URL{Scheme: "http", Host: "example.com", Path: "/bar"},
+					url.URL{Scheme: "http", Host: "example.com", Path: "/foo"},
 				},
 			},
 		},
@@ -600,3 +646,303 @@ func TestNewRegistry(t *testing.T) {
 		})
 	}
 }
+
+func TestNewRegistryWithOptions(t *testing.T) {
+	testCases := []struct {
+		in   string
+		opts RegistryOptions
+		err  error
+	}{
+		{
+			`{"elements":{"title":"a","description":"b","foo":"c"}}`,
+			RegistryOptions{},
+			nil,
+		},
+		{
+			`{"title":"a","description":"b","properties":{"a":{"description":"c"}}}`,
+			RegistryOptions{Strict: true},
+			nil,
+		},
+		{
+			`{"optionalProperty":{"a":{}}}`,
+			RegistryOptions{Strict: true},
+			ErrUnknownKeyword{Keyword: "optionalProperty"},
+		},
+		{
+			`{"elements":{"element":{},"b":1}}`,
+			RegistryOptions{Strict: true},
+			ErrUnknownKeyword{Keyword: "b"},
+		},
+		{
+			`{"definitions":{"a":{"foo":1}}}`,
+			RegistryOptions{Strict: true},
+			ErrUnknownKeyword{Keyword: "foo"},
+		},
+		{
+			`{"definitions":{"a":{"foo":1}}}`,
+			RegistryOptions{Strict: true, AllowedKeywords: []string{"foo"}},
+			nil,
+		},
+		{
+			`{"discriminator":{"propertyName":"t","mapping":{},"mappings":{}}}`,
+			RegistryOptions{},
+			nil,
+		},
+		{
+			`{"discriminator":{"propertyName":"t","mapping":{},"mappings":{}}}`,
+			RegistryOptions{Strict: true},
+			ErrUnknownKeyword{Keyword: "mappings"},
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			var schema SchemaStruct
+			assert.NoError(t, json.Unmarshal([]byte(tt.in), &schema))
+
+			_, err := NewRegistryWithOptions([]SchemaStruct{schema}, tt.opts)
+			assert.Equal(t, tt.err, errors.Cause(err))
+		})
+	}
+}
+
+func TestNewRegistrySchemaErrors(t *testing.T) {
+	var schemas []SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{
+			"id": "http://example.com/foo",
+			"definitions": {
+				"a": {"type": "foo"},
+				"b": {"elements": {"id": "bar"}}
+			}
+		},
+		{"type": "string"},
+		{
+			"properties": {
+				"a": {"enum": [], "title": "x", "optionalProperty": {}}
+			},
+			"values": {},
+			"discriminator": {
+				"propertyName": "t",
+				"mapping": {"a": {"ref": "::"}},
+				"mappings": {}
+			}
+		}
+	]`), &schemas))
+
+	_, err := NewRegistryWithOptions(schemas, RegistryOptions{Strict: true})
+	assert.Equal(t, SchemaErrors{
+		SchemaError{
+			Index:   0,
+			ID:      "http://example.com/foo",
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"definitions", "a"}},
+			Keyword: "type",
+			Code:    SchemaErrorCodeInvalidType,
+			Err:     e.New("invalid type: foo"),
+		},
+		SchemaError{
+			Index:   0,
+			ID:      "http://example.com/foo",
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"definitions", "b", "elements"}},
+			Keyword: "id",
+			Code:    SchemaErrorCodeBadSubSchema,
+			Err:     ErrBadSubSchema,
+		},
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"properties", "a"}},
+			Keyword: "optionalProperty",
+			Code:    SchemaErrorCodeUnknownKeyword,
+			Err:     ErrUnknownKeyword{Keyword: "optionalProperty"},
+		},
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"properties", "a"}},
+			Keyword: "enum",
+			Code:    SchemaErrorCodeInvalidEnum,
+			Err:     e.New("empty enum"),
+		},
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{}},
+			Keyword: "values",
+			Code:    SchemaErrorCodeBadKind,
+			Err:     ErrBadSchemaKind,
+		},
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{}},
+			Keyword: "discriminator",
+			Code:    SchemaErrorCodeBadKind,
+			Err:     ErrBadSchemaKind,
+		},
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator"}},
+			Keyword: "mappings",
+			Code:    SchemaErrorCodeUnknownKeyword,
+			Err:     ErrUnknownKeyword{Keyword: "mappings"},
+		},
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator", "mapping", "a"}},
+			Keyword: "ref",
+			Code:    SchemaErrorCodeInvalidURI,
+			Err: &url.Error{
+				Op:  "parse",
+				URL: "::",
+				Err: e.New("missing protocol scheme"),
+			},
+		},
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator", "mapping"}},
+			Keyword: "a",
+			Code:    SchemaErrorCodeInvalidDiscriminator,
+			Err:     e.New("mapping value must be a properties schema"),
+		},
+	}, err)
+}
+
+func TestNewRegistryRefCycle(t *testing.T) {
+	testCases := []struct {
+		in  []string
+		err error
+	}{
+		{
+			[]string{`{"definitions":{"a":{"ref":"#a"}}}`},
+			ErrRefCycle{URIs: []url.URL{url.URL{Fragment: "a"}}},
+		},
+		{
+			[]string{`{"ref":""}`},
+			ErrRefCycle{URIs: []url.URL{url.URL{}}},
+		},
+		{
+			[]string{`{"definitions":{"a":{"ref":"#b"},"b":{"nullable":true,"ref":"#a"}},"ref":"#a"}`},
+			ErrRefCycle{URIs: []url.URL{url.URL{Fragment: "b"}, url.URL{Fragment: "a"}}},
+		},
+		{
+			[]string{
+				`{"id":"http://example.com/a","ref":"b#c"}`,
+				`{"id":"http://example.com/b","definitions":{"c":{"ref":"a"}}}`,
+			},
+			ErrRefCycle{URIs: []url.URL{
+				url.URL{Scheme: "http", Host: "example.com", Path: "/b", Fragment: "c"},
+				url.URL{Scheme: "http", Host: "example.com", Path: "/a"},
+			}},
+		},
+		{
+			[]string{`{"definitions":{"a":{"elements":{"ref":"#a"}}},"ref":"#a"}`},
+			nil,
+		},
+		{
+			[]string{`{"definitions":{"a":{"properties":{"b":{"ref":"#a"}}},"b":{"ref":"#a"}},"ref":"#b"}`},
+			nil,
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			schemas := make([]SchemaStruct, len(tt.in))
+			for i, in := range tt.in {
+				assert.NoError(t, json.Unmarshal([]byte(in), &schemas[i]))
+			}
+
+			_, err := NewRegistry(schemas)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestErrRefCycle(t *testing.T) {
+	err := ErrRefCycle{URIs: []url.URL{url.URL{Fragment: "a"}, url.URL{Fragment: "b"}}}
+	assert.Equal(t, "ref cycle: #a -> #b -> #a", err.Error())
+}
+
+func TestNewRegistryDiscriminator(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"discriminator": {
+			"propertyName": "",
+			"mapping": {
+				"a": {"properties": {"b": {}}},
+				"b": {"type": "string"},
+				"c": {},
+				"d": {"properties": {"": {}}, "optionalProperties": {"": {}}}
+			}
+		}
+	}`), &schema))
+
+	_, err := NewRegistry([]SchemaStruct{schema})
+	assert.Equal(t, SchemaErrors{
+		SchemaError{
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator"}},
+			Keyword: "propertyName",
+			Code:    SchemaErrorCodeInvalidDiscriminator,
+			Err:     e.New("empty discriminator property name"),
+		},
+		SchemaError{
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator", "mapping"}},
+			Keyword: "b",
+			Code:    SchemaErrorCodeInvalidDiscriminator,
+			Err:     e.New("mapping value must be a properties schema"),
+		},
+		SchemaError{
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator", "mapping"}},
+			Keyword: "c",
+			Code:    SchemaErrorCodeInvalidDiscriminator,
+			Err:     e.New("mapping value must be a properties schema"),
+		},
+		SchemaError{
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator", "mapping", "d", "properties"}},
+			Keyword: "",
+			Code:    SchemaErrorCodeInvalidDiscriminator,
+			Err:     e.New("mapping value redeclares discriminator property: "),
+		},
+		SchemaError{
+			Ptr:     jsonpointer.Ptr{Tokens: []string{"discriminator", "mapping", "d", "optionalProperties"}},
+			Keyword: "",
+			Code:    SchemaErrorCodeInvalidDiscriminator,
+			Err:     e.New("mapping value redeclares discriminator property: "),
+		},
+	}, err)
+}
+
+func TestNewRegistryDuplicateID(t *testing.T) {
+	var schemas []SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"type": "string"},
+		{"id": "http://example.com/a", "type": "string"},
+		{"type": "number"},
+		{"id": "http://example.com/a", "type": "number"}
+	]`), &schemas))
+
+	_, err := NewRegistry(schemas)
+	assert.Equal(t, SchemaErrors{
+		SchemaError{
+			Index:   2,
+			Ptr:     jsonpointer.Ptr{Tokens: []string{}},
+			Keyword: "id",
+			Code:    SchemaErrorCodeDuplicateID,
+			Err:     ErrDuplicateID{FirstIndex: 0},
+		},
+		SchemaError{
+			Index:   3,
+			ID:      "http://example.com/a",
+			Ptr:     jsonpointer.Ptr{Tokens: []string{}},
+			Keyword: "id",
+			Code:    SchemaErrorCodeDuplicateID,
+			Err: ErrDuplicateID{
+				ID:         url.URL{Scheme: "http", Host: "example.com", Path: "/a"},
+				FirstIndex: 1,
+			},
+		},
+	}, err)
+
+	assert.Equal(t, `schema at index 3 (id: http://example.com/a): "": id: duplicate id: "http://example.com/a" (first used by schema at index 1)`, err.(SchemaErrors)[1].Error())
+
+	registry, err := NewRegistryWithOptions(schemas, RegistryOptions{ReplaceDuplicateIDs: true})
+	assert.NoError(t, err)
+	assert.Equal(t, SchemaTypeNumber, registry.Schemas[url.URL{}].Type)
+	assert.Equal(t, SchemaTypeNumber, registry.Schemas[url.URL{Scheme: "http", Host: "example.com", Path: "/a"}].Type)
+}
@@ -0,0 +1,294 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxResolved is how many schemas NewRegistryWithOptions fetches from a
+// SchemaResolver when RegistryOptions.MaxResolved is zero.
+const DefaultMaxResolved = 100
+
+// The limits which an HTTPResolver uses when the corresponding field is zero.
+const (
+	DefaultHTTPTimeout    = 10 * time.Second
+	DefaultMaxSchemaBytes = 10 << 20
+)
+
+var defaultHTTPClient = &http.Client{Timeout: DefaultHTTPTimeout}
+
+// SchemaResolver fetches schemas which a registry refers to, but which weren't
+// passed to NewRegistryWithOptions.
+//
+// Resolve is passed the URI of a root schema, never one with a fragment. If
+// the schema it returns has no "id", it is given uri as its id. Otherwise, its
+// id must be uri. If there is no schema with the given URI, Resolve should
+// return ErrSchemaNotFound, so that NewRegistryWithOptions reports it among
+// ErrMissingSchemas.
+type SchemaResolver interface {
+	Resolve(uri url.URL) (SchemaStruct, error)
+}
+
+// MapResolver is a SchemaResolver which looks up schemas in memory.
+type MapResolver map[url.URL]SchemaStruct
+
+func (r MapResolver) Resolve(uri url.URL) (SchemaStruct, error) {
+	schema, ok := r[uri]
+	if !ok {
+		return SchemaStruct{}, ErrSchemaNotFound
+	}
+
+	return schema, nil
+}
+
+// FSResolver is a SchemaResolver which reads schemas from a file system. It is
+// the counterpart of LoadFS with LoadOptions.IDBase: a URI is resolved to the
+// file at its path relative to Base.
+type FSResolver struct {
+	FS fs.FS
+
+	// Base is the URI of the root of FS. URIs which aren't within Base are
+	// not found. A nil Base is the same as an empty one, under which only
+	// relative URIs are found.
+	Base *url.URL
+}
+
+func (r FSResolver) Resolve(uri url.URL) (SchemaStruct, error) {
+	var base url.URL
+	if r.Base != nil {
+		base = *r.Base
+	}
+
+	// Only whole segments of Base's path are matched, so that a Base of
+	// "/schemas" doesn't contain "/schemas-other".
+	prefix := base.Path
+	if prefix != "" {
+		prefix = strings.TrimSuffix(path.Clean(prefix), "/") + "/"
+	} else if base.Scheme != "" || base.Host != "" {
+		prefix = "/"
+	}
+
+	if uri.Scheme != base.Scheme || uri.Host != base.Host || uri.RawQuery != "" || !strings.HasPrefix(uri.Path, prefix) {
+		return SchemaStruct{}, ErrSchemaNotFound
+	}
+
+	p := strings.TrimPrefix(uri.Path, prefix)
+	if !fs.ValidPath(p) {
+		return SchemaStruct{}, ErrSchemaNotFound
+	}
+
+	data, err := fs.ReadFile(r.FS, p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return SchemaStruct{}, ErrSchemaNotFound
+	}
+
+	if err != nil {
+		return SchemaStruct{}, err
+	}
+
+	var schema SchemaStruct
+	err = json.Unmarshal(data, &schema)
+	return schema, err
+}
+
+// HTTPResolver is a SchemaResolver which fetches schemas with HTTP GET
+// requests. URIs whose scheme is not "http" or "https", and those for which the
+// server responds 404 Not Found or 410 Gone, are not found.
+type HTTPResolver struct {
+	// Client makes the requests. If nil, a client which gives up on requests
+	// after DefaultHTTPTimeout is used.
+	Client *http.Client
+
+	// MaxBytes is the size of the largest schema which may be fetched. If it
+	// is zero, DefaultMaxSchemaBytes is used; Unlimited disables the limit.
+	MaxBytes int
+}
+
+func (r HTTPResolver) Resolve(uri url.URL) (SchemaStruct, error) {
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return SchemaStruct{}, ErrSchemaNotFound
+	}
+
+	client := r.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+
+	resp, err := client.Get(uri.String())
+	if err != nil {
+		return SchemaStruct{}, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return SchemaStruct{}, ErrSchemaNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return SchemaStruct{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	body := io.Reader(resp.Body)
+	maxBytes := limit(r.MaxBytes, DefaultMaxSchemaBytes)
+	if maxBytes != Unlimited {
+		// Read one byte too many, to tell whether the limit was exceeded.
+		body = io.LimitReader(body, int64(maxBytes)+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return SchemaStruct{}, err
+	}
+
+	if maxBytes != Unlimited && len(data) > maxBytes {
+		return SchemaStruct{}, fmt.Errorf("schema is larger than %d bytes", maxBytes)
+	}
+
+	var schema SchemaStruct
+	err = json.Unmarshal(data, &schema)
+	return schema, err
+}
+
+// CachingResolver wraps a SchemaResolver, remembering the schemas it returns,
+// and which URIs it found no schema for. Other errors are not remembered, so
+// that the URI is tried again next time.
+//
+// A CachingResolver is safe for concurrent use, so one may be shared between
+// every registry constructed by a program.
+type CachingResolver struct {
+	resolver SchemaResolver
+
+	mu    sync.Mutex
+	cache map[url.URL]cachedSchema
+}
+
+type cachedSchema struct {
+	schema SchemaStruct
+	err    error
+}
+
+// NewCachingResolver constructs a CachingResolver which fetches schemas from
+// resolver.
+func NewCachingResolver(resolver SchemaResolver) *CachingResolver {
+	return &CachingResolver{resolver: resolver, cache: map[url.URL]cachedSchema{}}
+}
+
+func (r *CachingResolver) Resolve(uri url.URL) (SchemaStruct, error) {
+	r.mu.Lock()
+	cached, ok := r.cache[uri]
+	r.mu.Unlock()
+
+	if ok {
+		return cached.schema, cached.err
+	}
+
+	schema, err := r.resolver.Resolve(uri)
+	if err == nil || errors.Is(err, ErrSchemaNotFound) {
+		r.mu.Lock()
+		r.cache[uri] = cachedSchema{schema: schema, err: err}
+		r.mu.Unlock()
+	}
+
+	return schema, err
+}
+
+// resolveSchemas adds to schemas each schema in missing which can be fetched
+// from opts.Resolver, along with those they refer to in turn. It returns the
+// URIs which are still missing once no more schemas can be fetched.
+//
+// Only the refs of the schemas in resolve, which is where missing came from,
+// are resolved again once schemas have been fetched, and the fetched schemas
+// are added to resolve too. Any other schemas may be shared with another
+// Registry, so they must not be modified.
+func resolveSchemas(schemas, resolve map[url.URL]*Schema, missing []url.URL, opts RegistryOptions) ([]url.URL, error) {
+	maxResolved := limit(opts.MaxResolved, DefaultMaxResolved)
+	attempted := map[url.URL]bool{}
+	resolved := 0
+
+	for {
+		var uris []url.URL
+		for _, uri := range missing {
+			uri.Fragment = ""
+			if _, ok := schemas[uri]; !ok && !attempted[uri] {
+				attempted[uri] = true
+				uris = append(uris, uri)
+			}
+		}
+
+		// Any refs which are still missing can't be fetched, either because
+		// Resolver doesn't have them, or because they are to definitions
+		// which don't exist.
+		if len(uris) == 0 {
+			return missing, nil
+		}
+
+		// Sort, so that the same schemas are fetched in the same order each
+		// time.
+		sort.Slice(uris, func(i, j int) bool {
+			return uris[i].String() < uris[j].String()
+		})
+
+		for _, uri := range uris {
+			if resolved == maxResolved {
+				return nil, ErrMaxResolved
+			}
+
+			schema, err := opts.Resolver.Resolve(uri)
+			if errors.Is(err, ErrSchemaNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return nil, ErrResolve{URI: uri, Err: err}
+			}
+
+			s, err := parseResolved(uri, schema, opts)
+			if err != nil {
+				return nil, ErrResolve{URI: uri, Err: err}
+			}
+
+			schemas[uri] = s
+			resolve[uri] = s
+			resolved++
+		}
+
+		// The schemas just fetched may both satisfy refs which were missing,
+		// and make refs of their own.
+		missing = []url.URL{}
+		for _, schema := range resolve {
+			populateSchemaRefs(&missing, schemas, schema.ID, schema)
+		}
+	}
+}
+
+// parseResolved parses a schema which was fetched from uri.
+func parseResolved(uri url.URL, schema SchemaStruct, opts RegistryOptions) (*Schema, error) {
+	p := schemaParser{opts: opts, id: uri.String()}
+	if schema.ID == nil {
+		schema.ID = &p.id
+	} else {
+		p.id = *schema.ID
+	}
+
+	s := p.parse([]string{}, true, schema)
+	if len(p.errors) > 0 {
+		return nil, SchemaErrors(p.errors)
+	}
+
+	if *s.ID != uri {
+		return nil, fmt.Errorf("schema has a different id: %s", s.ID.String())
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,217 @@
+package jsonvalidate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustParseURI(t *testing.T, s string) url.URL {
+	uri, err := url.Parse(s)
+	assert.NoError(t, err)
+	return *uri
+}
+
+func TestResolver(t *testing.T) {
+	resolver := MapResolver{
+		mustParseURI(t, "http://example.com/a"): unmarshalSchema(t, `{"ref":"b"}`),
+		mustParseURI(t, "http://example.com/b"): unmarshalSchema(t, `{"id":"http://example.com/b","definitions":{"c":{"ref":"c"}}}`),
+		mustParseURI(t, "http://example.com/c"): unmarshalSchema(t, `{"type":"string"}`),
+	}
+
+	root := unmarshalSchema(t, `{"id":"http://example.com/root","properties":{"a":{"ref":"a"},"c":{"ref":"b#c"}}}`)
+
+	_, err := NewRegistry([]SchemaStruct{root})
+	_, ok := err.(ErrMissingSchemas)
+	assert.True(t, ok)
+
+	registry, err := NewRegistryWithOptions([]SchemaStruct{root}, RegistryOptions{Resolver: resolver})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(registry.Schemas))
+
+	validator := Validator{Registry: registry}
+	result, err := validator.ValidateURI(mustParseURI(t, "http://example.com/root"), map[string]interface{}{"a": "x", "c": "y"})
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	// Refs which the resolver can't satisfy are still missing.
+	root = unmarshalSchema(t, `{"properties":{"a":{"ref":"http://example.com/b#d"},"b":{"ref":"http://example.com/d"}}}`)
+	_, err = NewRegistryWithOptions([]SchemaStruct{root}, RegistryOptions{Resolver: resolver})
+	assert.Equal(t, 2, len(err.(ErrMissingSchemas).URIs))
+}
+
+func TestResolverErrors(t *testing.T) {
+	resolver := MapResolver{
+		mustParseURI(t, "http://example.com/a"): unmarshalSchema(t, `{"id":"http://example.com/b"}`),
+		mustParseURI(t, "http://example.com/b"): unmarshalSchema(t, `{"type":"foo"}`),
+		mustParseURI(t, "http://example.com/c"): unmarshalSchema(t, `{"ref":"c"}`),
+	}
+
+	_, err := NewRegistryWithOptions([]SchemaStruct{unmarshalSchema(t, `{"ref":"http://example.com/a"}`)}, RegistryOptions{Resolver: resolver})
+	resolveErr, ok := err.(ErrResolve)
+	assert.True(t, ok)
+	assert.Equal(t, "http://example.com/a", resolveErr.URI.String())
+
+	_, err = NewRegistryWithOptions([]SchemaStruct{unmarshalSchema(t, `{"ref":"http://example.com/b"}`)}, RegistryOptions{Resolver: resolver})
+	resolveErr, ok = err.(ErrResolve)
+	assert.True(t, ok)
+	assert.Equal(t, SchemaErrorCodeInvalidType, resolveErr.Err.(SchemaErrors)[0].Code)
+
+	_, err = NewRegistryWithOptions([]SchemaStruct{unmarshalSchema(t, `{"ref":"http://example.com/c"}`)}, RegistryOptions{Resolver: resolver})
+	_, ok = err.(ErrRefCycle)
+	assert.True(t, ok)
+}
+
+func TestMaxResolved(t *testing.T) {
+	resolver := MapResolver{
+		mustParseURI(t, "http://example.com/a"): unmarshalSchema(t, `{"ref":"b"}`),
+		mustParseURI(t, "http://example.com/b"): unmarshalSchema(t, `{"ref":"c"}`),
+		mustParseURI(t, "http://example.com/c"): unmarshalSchema(t, `{}`),
+	}
+
+	root := unmarshalSchema(t, `{"ref":"http://example.com/a"}`)
+
+	_, err := NewRegistryWithOptions([]SchemaStruct{root}, RegistryOptions{Resolver: resolver, MaxResolved: 2})
+	assert.Equal(t, ErrMaxResolved, err)
+
+	_, err = NewRegistryWithOptions([]SchemaStruct{root}, RegistryOptions{Resolver: resolver, MaxResolved: 3})
+	assert.NoError(t, err)
+
+	_, err = NewRegistryWithOptions([]SchemaStruct{root}, RegistryOptions{Resolver: resolver, MaxResolved: Unlimited})
+	assert.NoError(t, err)
+}
+
+func TestFSResolver(t *testing.T) {
+	fsys := fstest.MapFS{
+		"users/user.json": {Data: []byte(`{"properties":{"name":{"ref":"name.json"}}}`)},
+		"users/name.json": {Data: []byte(`{"type":"string"}`)},
+		"bad.json":        {Data: []byte(`{"type":`)},
+	}
+
+	base := mustParseURI(t, "http://example.com/schemas/")
+	resolver := FSResolver{FS: fsys, Base: &base}
+
+	root := unmarshalSchema(t, `{"elements":{"ref":"http://example.com/schemas/users/user.json"}}`)
+	registry, err := NewRegistryWithOptions([]SchemaStruct{root}, RegistryOptions{Resolver: resolver})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(registry.Schemas))
+
+	for _, uri := range []string{
+		"http://example.com/schemas/users/missing.json",
+		"http://example.com/other/users/user.json",
+		"https://example.com/schemas/users/user.json",
+		"http://example.com/schemas/../schemas/users/user.json",
+	} {
+		_, err := resolver.Resolve(mustParseURI(t, uri))
+		assert.Equal(t, ErrSchemaNotFound, err)
+	}
+
+	_, err = resolver.Resolve(mustParseURI(t, "http://example.com/schemas/bad.json"))
+	assert.Error(t, err)
+
+	// A Base without a trailing slash still only contains whole segments.
+	fsys["x.json"] = &fstest.MapFile{Data: []byte(`{}`)}
+	fsys["-other/x.json"] = &fstest.MapFile{Data: []byte(`{}`)}
+	for _, tt := range []struct {
+		base  string
+		uri   string
+		found bool
+	}{
+		{"http://example.com/schemas", "http://example.com/schemas/x.json", true},
+		{"http://example.com/schemas", "http://example.com/schemas-other/x.json", false},
+		{"http://example.com/schemas/", "http://example.com/schemas-other/x.json", false},
+		{"http://example.com", "http://example.com/x.json", true},
+		{"http://example.com/", "http://example.com/x.json", true},
+		{"", "x.json", true},
+	} {
+		base := mustParseURI(t, tt.base)
+		_, err := FSResolver{FS: fsys, Base: &base}.Resolve(mustParseURI(t, tt.uri))
+		if tt.found {
+			assert.NoError(t, err)
+		} else {
+			assert.Equal(t, ErrSchemaNotFound, err)
+		}
+	}
+}
+
+func TestHTTPResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			w.Write([]byte(`{"ref":"b"}`))
+		case "/b":
+			w.Write([]byte(`{"type":"string"}`))
+		case "/large":
+			w.Write([]byte(`{"description":"` + strings.Repeat("x", 2048) + `"}`))
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	defer server.Close()
+
+	resolver := HTTPResolver{Client: server.Client()}
+
+	root := unmarshalSchema(t, `{"elements":{"ref":"`+server.URL+`/a"}}`)
+	registry, err := NewRegistryWithOptions([]SchemaStruct{root}, RegistryOptions{Resolver: resolver})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(registry.Schemas))
+
+	_, err = resolver.Resolve(mustParseURI(t, server.URL+"/missing"))
+	assert.Equal(t, ErrSchemaNotFound, err)
+
+	_, err = resolver.Resolve(mustParseURI(t, "urn:example:a"))
+	assert.Equal(t, ErrSchemaNotFound, err)
+
+	_, err = resolver.Resolve(mustParseURI(t, server.URL+"/error"))
+	assert.Error(t, err)
+
+	_, err = resolver.Resolve(mustParseURI(t, server.URL+"/large"))
+	assert.NoError(t, err)
+
+	_, err = HTTPResolver{Client: server.Client(), MaxBytes: 1024}.Resolve(mustParseURI(t, server.URL+"/large"))
+	assert.Error(t, err)
+
+	// Without a Client, a default one is used.
+	schema, err := HTTPResolver{}.Resolve(mustParseURI(t, server.URL+"/b"))
+	assert.NoError(t, err)
+	assert.Equal(t, "string", *schema.Type)
+}
+
+type countingResolver struct {
+	resolver SchemaResolver
+	counts   map[url.URL]int
+}
+
+func (r countingResolver) Resolve(uri url.URL) (SchemaStruct, error) {
+	r.counts[uri]++
+	return r.resolver.Resolve(uri)
+}
+
+func TestCachingResolver(t *testing.T) {
+	counting := countingResolver{
+		resolver: MapResolver{mustParseURI(t, "http://example.com/a"): unmarshalSchema(t, `{}`)},
+		counts:   map[url.URL]int{},
+	}
+
+	resolver := NewCachingResolver(counting)
+	for i := 0; i < 3; i++ {
+		_, err := resolver.Resolve(mustParseURI(t, "http://example.com/a"))
+		assert.NoError(t, err)
+
+		_, err = resolver.Resolve(mustParseURI(t, "http://example.com/b"))
+		assert.Equal(t, ErrSchemaNotFound, err)
+	}
+
+	assert.Equal(t, map[url.URL]int{
+		mustParseURI(t, "http://example.com/a"): 1,
+		mustParseURI(t, "http://example.com/b"): 1,
+	}, counting.counts)
+}
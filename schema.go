@@ -2,6 +2,7 @@ package jsonvalidate
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/url"
 )
 
@@ -16,15 +17,18 @@ import (
 // NewRegistry to construct a registry using your schema. That function handles
 // checking all the rules about what makes a schema valid.
 type SchemaStruct struct {
-	ID                 *string                    `json:"id,omitempty"`
-	Ref                *string                    `json:"ref,omitempty"`
-	Definitions        *map[string]SchemaStruct   `json:"definitions,omitempty"`
-	Type               *string                    `json:"type,omitempty"`
-	Elements           *SchemaStruct              `json:"elements,omitempty"`
-	Properties         *map[string]SchemaStruct   `json:"properties,omitempty"`
-	OptionalProperties *map[string]SchemaStruct   `json:"optionalProperties,omitempty"`
-	Values             *SchemaStruct              `json:"values,omitempty"`
-	Discriminator      *SchemaStructDiscriminator `json:"discriminator,omitempty"`
+	ID                   *string                    `json:"id,omitempty"`
+	Ref                  *string                    `json:"ref,omitempty"`
+	Definitions          *map[string]SchemaStruct   `json:"definitions,omitempty"`
+	Type                 *string                    `json:"type,omitempty"`
+	Enum                 *[]string                  `json:"enum,omitempty"`
+	Elements             *SchemaStruct              `json:"elements,omitempty"`
+	Properties           *map[string]SchemaStruct   `json:"properties,omitempty"`
+	OptionalProperties   *map[string]SchemaStruct   `json:"optionalProperties,omitempty"`
+	AdditionalProperties *bool                      `json:"additionalProperties,omitempty"`
+	Values               *SchemaStruct              `json:"values,omitempty"`
+	Discriminator        *SchemaStructDiscriminator `json:"discriminator,omitempty"`
+	Nullable             *bool                      `json:"nullable,omitempty"`
 
 	// Extra stores data that's in a schema, but isn't part of the formal spec.
 	//
@@ -38,6 +42,51 @@ type SchemaStruct struct {
 type SchemaStructDiscriminator struct {
 	PropertyName string                  `json:"propertyName"`
 	Mapping      map[string]SchemaStruct `json:"mapping"`
+
+	// Extra stores data that's in a discriminator, but isn't part of the
+	// formal spec, such as a misspelled keyword.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (d *SchemaStructDiscriminator) UnmarshalJSON(data []byte) error {
+	type schemaStructDiscriminator SchemaStructDiscriminator
+	var raw schemaStructDiscriminator
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var extra map[string]interface{}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+
+	d.PropertyName = raw.PropertyName
+	delete(extra, "propertyName")
+
+	d.Mapping = raw.Mapping
+	delete(extra, "mapping")
+
+	d.Extra = extra
+	return nil
+}
+
+// MarshalJSON satisfies the json.Marshaler interface.
+func (d SchemaStructDiscriminator) MarshalJSON() ([]byte, error) {
+	type schemaStructDiscriminator SchemaStructDiscriminator
+	out, err := json.Marshal(schemaStructDiscriminator(d))
+	if err != nil || len(d.Extra) == 0 {
+		return out, err
+	}
+
+	// Extra keys come after those of the spec.
+	extra, err := json.Marshal(d.Extra)
+	if err != nil {
+		return nil, err
+	}
+
+	out = append(out[:len(out)-1], ',')
+	return append(out, extra[1:]...), nil
 }
 
 // UnmarshalJSON satisfies the json.Unmarshaler interface.
@@ -65,6 +114,9 @@ func (s *SchemaStruct) UnmarshalJSON(data []byte) error {
 	s.Type = raw.Type
 	delete(extra, "type")
 
+	s.Enum = raw.Enum
+	delete(extra, "enum")
+
 	s.Elements = raw.Elements
 	delete(extra, "elements")
 
@@ -74,12 +126,18 @@ func (s *SchemaStruct) UnmarshalJSON(data []byte) error {
 	s.OptionalProperties = raw.OptionalProperties
 	delete(extra, "optionalProperties")
 
+	s.AdditionalProperties = raw.AdditionalProperties
+	delete(extra, "additionalProperties")
+
 	s.Values = raw.Values
 	delete(extra, "values")
 
 	s.Discriminator = raw.Discriminator
 	delete(extra, "discriminator")
 
+	s.Nullable = raw.Nullable
+	delete(extra, "nullable")
+
 	s.Extra = extra
 	return nil
 }
@@ -107,6 +165,10 @@ func (s SchemaStruct) MarshalJSON() ([]byte, error) {
 		out["type"] = s.Type
 	}
 
+	if s.Enum != nil {
+		out["enum"] = s.Enum
+	}
+
 	if s.Elements != nil {
 		out["elements"] = s.Elements
 	}
@@ -119,6 +181,10 @@ func (s SchemaStruct) MarshalJSON() ([]byte, error) {
 		out["optionalProperties"] = s.OptionalProperties
 	}
 
+	if s.AdditionalProperties != nil {
+		out["additionalProperties"] = s.AdditionalProperties
+	}
+
 	if s.Values != nil {
 		out["values"] = s.Values
 	}
@@ -127,6 +193,10 @@ func (s SchemaStruct) MarshalJSON() ([]byte, error) {
 		out["discriminator"] = s.Discriminator
 	}
 
+	if s.Nullable != nil {
+		out["nullable"] = s.Nullable
+	}
+
 	return json.Marshal(out)
 }
 
@@ -154,6 +224,10 @@ type Schema struct {
 	// Indicates which keywords may be set on this schema.
 	Kind SchemaKind
 
+	// Whether null is accepted in addition to whatever Kind accepts. Nullable
+	// may be set regardless of Kind.
+	Nullable bool
+
 	// Meaningful iff Kind is SchemaKindRef.
 	Ref       *url.URL // the parsed URI that was referred to
 	RefSchema *Schema  // the schema the ref resolved to
@@ -161,12 +235,16 @@ type Schema struct {
 	// Meaningful iff Kind is SchemaKindType
 	Type SchemaType
 
+	// Meaningful iff Kind is SchemaKindEnum
+	Enum map[string]struct{} // the set of permitted values
+
 	// Meaningful iff Kind is SchemaKindElements
 	Elements *Schema
 
 	// Meaningful iff Kind is SchemaKindProperties
-	Properties         map[string]*Schema // required properties
-	OptionalProperties map[string]*Schema // optional properties
+	Properties           map[string]*Schema // required properties
+	OptionalProperties   map[string]*Schema // optional properties
+	AdditionalProperties *bool              // nil means the Validator decides
 
 	// Meaningful iff Kind is SchemaKindValues
 	Values *Schema
@@ -211,6 +289,9 @@ const (
 	// SchemaKindDiscriminator indidcates a schema with the "discriminator"
 	// keyword.
 	SchemaKindDiscriminator
+
+	// SchemaKindEnum indicates a schema with the "enum" keyword.
+	SchemaKindEnum
 )
 
 // SchemaType indicates possible values of the "type" keyword.
@@ -228,4 +309,65 @@ const (
 
 	// SchemaTypeString indicates the type "string".
 	SchemaTypeString
+
+	// SchemaTypeInt8 indicates the type "int8".
+	SchemaTypeInt8
+
+	// SchemaTypeUint8 indicates the type "uint8".
+	SchemaTypeUint8
+
+	// SchemaTypeInt16 indicates the type "int16".
+	SchemaTypeInt16
+
+	// SchemaTypeUint16 indicates the type "uint16".
+	SchemaTypeUint16
+
+	// SchemaTypeInt32 indicates the type "int32".
+	SchemaTypeInt32
+
+	// SchemaTypeUint32 indicates the type "uint32".
+	SchemaTypeUint32
+
+	// SchemaTypeFloat32 indicates the type "float32".
+	SchemaTypeFloat32
+
+	// SchemaTypeFloat64 indicates the type "float64".
+	SchemaTypeFloat64
+
+	// SchemaTypeTimestamp indicates the type "timestamp".
+	SchemaTypeTimestamp
 )
+
+// String returns the value of the "type" keyword which t indicates.
+func (t SchemaType) String() string {
+	switch t {
+	case SchemaTypeNull:
+		return "null"
+	case SchemaTypeBoolean:
+		return "boolean"
+	case SchemaTypeNumber:
+		return "number"
+	case SchemaTypeString:
+		return "string"
+	case SchemaTypeInt8:
+		return "int8"
+	case SchemaTypeUint8:
+		return "uint8"
+	case SchemaTypeInt16:
+		return "int16"
+	case SchemaTypeUint16:
+		return "uint16"
+	case SchemaTypeInt32:
+		return "int32"
+	case SchemaTypeUint32:
+		return "uint32"
+	case SchemaTypeFloat32:
+		return "float32"
+	case SchemaTypeFloat64:
+		return "float64"
+	case SchemaTypeTimestamp:
+		return "timestamp"
+	}
+
+	return fmt.Sprintf("SchemaType(%d)", int(t))
+}
@@ -12,6 +12,7 @@ func TestSchemaJSONRoundtrip(t *testing.T) {
 	// you can't take a pointer to a string literal, so here we are
 	var strEmpty = ""
 	var strA = "a"
+	var boolTrue = true
 
 	testCases := []struct {
 		in  string
@@ -67,6 +68,29 @@ func TestSchemaJSONRoundtrip(t *testing.T) {
 				Extra: map[string]interface{}{},
 			},
 		},
+		{
+			`{"enum":["a",""]}`,
+			SchemaStruct{
+				Enum:  &[]string{"a", ""},
+				Extra: map[string]interface{}{},
+			},
+		},
+		{
+			`{"nullable":true,"type":"a"}`,
+			SchemaStruct{
+				Type:     &strA,
+				Nullable: &boolTrue,
+				Extra:    map[string]interface{}{},
+			},
+		},
+		{
+			`{"additionalProperties":true,"properties":{}}`,
+			SchemaStruct{
+				Properties:           &map[string]SchemaStruct{},
+				AdditionalProperties: &boolTrue,
+				Extra:                map[string]interface{}{},
+			},
+		},
 		{
 			`{"elements":{"id":""}}`,
 			SchemaStruct{
@@ -134,6 +158,18 @@ func TestSchemaJSONRoundtrip(t *testing.T) {
 							Extra: map[string]interface{}{},
 						},
 					},
+					Extra: map[string]interface{}{},
+				},
+				Extra: map[string]interface{}{},
+			},
+		},
+		{
+			`{"discriminator":{"propertyName":"a","mapping":{},"mappings":{}}}`,
+			SchemaStruct{
+				Discriminator: &SchemaStructDiscriminator{
+					PropertyName: "a",
+					Mapping:      map[string]SchemaStruct{},
+					Extra:        map[string]interface{}{"mappings": map[string]interface{}{}},
 				},
 				Extra: map[string]interface{}{},
 			},
@@ -149,7 +185,7 @@ func TestSchemaJSONRoundtrip(t *testing.T) {
 				Properties:         &map[string]SchemaStruct{},
 				OptionalProperties: &map[string]SchemaStruct{},
 				Values:             &SchemaStruct{Extra: map[string]interface{}{}},
-				Discriminator:      &SchemaStructDiscriminator{PropertyName: "", Mapping: map[string]SchemaStruct{}},
+				Discriminator:      &SchemaStructDiscriminator{PropertyName: "", Mapping: map[string]SchemaStruct{}, Extra: map[string]interface{}{}},
 				Extra: map[string]interface{}{
 					"a": []interface{}{1.0},
 					"b": map[string]interface{}{},
@@ -0,0 +1,450 @@
+package jsonvalidate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+)
+
+// ValidateReader validates a single JSON value read from r against the default
+// schema, without first decoding the value into memory.
+func (v Validator) ValidateReader(r io.Reader) (ValidationResult, error) {
+	return v.ValidateDecoder(url.URL{}, json.NewDecoder(r))
+}
+
+// ValidateDecoder reads the next JSON value from decoder, and validates it
+// against the schema with the given URI.
+//
+// The value is validated token by token, in lockstep with the schema, so that
+// arbitrarily large values can be validated without holding them in memory.
+// The only exception is values validated against a schema with the
+// "discriminator" keyword, which are decoded in full so that the discriminator
+// property can be found.
+//
+// Once ValidateDecoder returns without error, the entire value has been read
+// from decoder, even if validation stopped early because of MaxErrors. If there
+// are no more values in decoder, the returned error is io.EOF.
+func (v Validator) ValidateDecoder(uri url.URL, decoder *json.Decoder) (ValidationResult, error) {
+	return v.ValidateDecoderContext(context.Background(), uri, decoder)
+}
+
+// ValidateDecoderContext is like ValidateDecoder, but stops validating once ctx
+// is done. In that case, it returns the errors found so far, along with
+// ctx.Err(), and the rest of the value is left unread in decoder.
+func (v Validator) ValidateDecoderContext(ctx context.Context, uri url.URL, decoder *json.Decoder) (ValidationResult, error) {
+	schema, ok := v.Registry.Schemas[uri]
+	if !ok {
+		return ValidationResult{}, fmt.Errorf("no schema with uri: %s", uri.String())
+	}
+
+	vm := v.newVM(&uri)
+	vm.done = ctx.Done()
+	stream := tokenStream{decoder: decoder}
+	if err := vm.evalStream(schema, &stream); err != nil {
+		if err == errDone {
+			return ValidationResult{Errors: vm.errors}, ctx.Err()
+		}
+
+		if err != errMaxErrors {
+			return ValidationResult{}, err
+		}
+
+		// Validation stopped partway through the value. Consume the rest of it,
+		// so that decoder is left positioned at the next value.
+		for stream.depth > 0 {
+			if _, err := stream.token(); err != nil {
+				return ValidationResult{}, err
+			}
+		}
+	}
+
+	return ValidationResult{Errors: vm.errors}, nil
+}
+
+// tokenStream wraps a json.Decoder, keeping track of how deeply nested within
+// arrays and objects the decoder is.
+type tokenStream struct {
+	decoder *json.Decoder
+	depth   int
+	next    json.Token // the token returned by peek, if hasNext
+	hasNext bool
+}
+
+func (s *tokenStream) token() (json.Token, error) {
+	if s.hasNext {
+		token := s.next
+		s.next = nil
+		s.hasNext = false
+		return token, nil
+	}
+
+	token, err := s.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('['), json.Delim('{'):
+		s.depth++
+	case json.Delim(']'), json.Delim('}'):
+		s.depth--
+	}
+
+	return token, nil
+}
+
+// peek returns the next token, without consuming it.
+func (s *tokenStream) peek() (json.Token, error) {
+	if !s.hasNext {
+		token, err := s.token()
+		if err != nil {
+			return nil, err
+		}
+
+		s.next = token
+		s.hasNext = true
+	}
+
+	return s.next, nil
+}
+
+// decode reads an entire value into memory.
+func (s *tokenStream) decode() (interface{}, error) {
+	token, err := s.token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('['):
+		elems := []interface{}{}
+		for s.more() {
+			elem, err := s.decode()
+			if err != nil {
+				return nil, err
+			}
+
+			elems = append(elems, elem)
+		}
+
+		_, err := s.token()
+		return elems, err
+	case json.Delim('{'):
+		object := map[string]interface{}{}
+		for s.more() {
+			key, err := s.token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := s.decode()
+			if err != nil {
+				return nil, err
+			}
+
+			object[key.(string)] = value
+		}
+
+		_, err := s.token()
+		return object, err
+	}
+
+	return token, nil
+}
+
+func (s *tokenStream) more() bool {
+	// Tokens are only ever peeked where a value is expected, so a peeked token
+	// is never the end of an array or object.
+	return s.hasNext || s.decoder.More()
+}
+
+// skip consumes the remainder of a value whose first token has already been
+// read.
+func (s *tokenStream) skip(first json.Token) error {
+	if first != json.Delim('[') && first != json.Delim('{') {
+		return nil
+	}
+
+	depth := s.depth - 1
+	for s.depth > depth {
+		if _, err := s.token(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (vm *vm) evalStream(schema *Schema, stream *tokenStream) error {
+	if err := vm.checkDone(); err != nil {
+		return err
+	}
+
+	if schema.Nullable {
+		token, err := stream.peek()
+		if err != nil {
+			return err
+		}
+
+		if token == nil {
+			_, err := stream.token()
+			return err
+		}
+	}
+
+	if schema.RefSchema != nil {
+		tokens := []string{}
+		if schema.Ref.Fragment != "" {
+			tokens = []string{"definitions", schema.Ref.Fragment}
+		}
+
+		if err := vm.pushSchema(schema.RefSchema.Base, tokens); err != nil {
+			return err
+		}
+
+		if err := vm.evalStream(schema.RefSchema, stream); err != nil {
+			return err
+		}
+
+		vm.popSchema()
+		return nil
+	}
+
+	// Validating against a discriminator requires knowing the value of the
+	// discriminator property before anything else, and that property may appear
+	// anywhere in the object.
+	if schema.Kind == SchemaKindDiscriminator {
+		instance, err := stream.decode()
+		if err != nil {
+			return err
+		}
+
+		return vm.eval(schema, instance)
+	}
+
+	token, err := stream.token()
+	if err != nil {
+		return err
+	}
+
+	switch schema.Kind {
+	case SchemaKindType:
+		if !typeMatches(schema.Type, token, vm.strictNumbers) {
+			vm.pushSchemaToken("type")
+			if err := vm.reportError(schema, "type"); err != nil {
+				return err
+			}
+			vm.popSchemaToken()
+		}
+	case SchemaKindEnum:
+		if !enumContains(schema.Enum, token) {
+			vm.pushSchemaToken("enum")
+			if err := vm.reportError(schema, "enum"); err != nil {
+				return err
+			}
+			vm.popSchemaToken()
+		}
+	case SchemaKindElements:
+		vm.pushSchemaToken("elements")
+
+		if token == json.Delim('[') {
+			if err := vm.checkInstanceDepth(); err != nil {
+				return err
+			}
+
+			for i := 0; stream.more(); i++ {
+				vm.pushInstanceToken(strconv.Itoa(i))
+				if err := vm.evalStream(schema.Elements, stream); err != nil {
+					return err
+				}
+				vm.popInstanceToken()
+			}
+
+			// Consume the closing "]".
+			if _, err := stream.token(); err != nil {
+				return err
+			}
+
+			vm.popSchemaToken()
+			return nil
+		}
+
+		if err := vm.reportError(schema, "elements"); err != nil {
+			return err
+		}
+
+		vm.popSchemaToken()
+	case SchemaKindProperties:
+		if token == json.Delim('{') {
+			if err := vm.checkInstanceDepth(); err != nil {
+				return err
+			}
+
+			seen := make(map[string]bool, len(schema.Properties))
+			for stream.more() {
+				key, err := stream.token()
+				if err != nil {
+					return err
+				}
+
+				property := key.(string)
+				if err := vm.evalStreamProperty(schema, property, stream); err != nil {
+					return err
+				}
+
+				seen[property] = true
+			}
+
+			// Consume the closing "}".
+			if _, err := stream.token(); err != nil {
+				return err
+			}
+
+			// Only once the entire object has been read is it known which required
+			// properties are missing.
+			vm.pushSchemaToken("properties")
+			for _, property := range sortedSchemaKeys(schema.Properties) {
+				if !seen[property] {
+					vm.pushSchemaToken(property)
+					if err := vm.reportError(schema, "properties"); err != nil {
+						return err
+					}
+					vm.popSchemaToken()
+				}
+			}
+			vm.popSchemaToken()
+
+			return nil
+		}
+
+		// Which errors we're gonna produce has to do with which keywords appeared
+		// in the schema.
+
+		if schema.Properties != nil {
+			vm.pushSchemaToken("properties")
+			if err := vm.reportError(schema, "properties"); err != nil {
+				return err
+			}
+			vm.popSchemaToken()
+		}
+
+		if schema.OptionalProperties != nil {
+			vm.pushSchemaToken("optionalProperties")
+			if err := vm.reportError(schema, "optionalProperties"); err != nil {
+				return err
+			}
+			vm.popSchemaToken()
+		}
+	case SchemaKindValues:
+		vm.pushSchemaToken("values")
+
+		if token == json.Delim('{') {
+			if err := vm.checkInstanceDepth(); err != nil {
+				return err
+			}
+
+			for stream.more() {
+				key, err := stream.token()
+				if err != nil {
+					return err
+				}
+
+				vm.pushInstanceToken(key.(string))
+				if err := vm.evalStream(schema.Values, stream); err != nil {
+					return err
+				}
+				vm.popInstanceToken()
+			}
+
+			// Consume the closing "}".
+			if _, err := stream.token(); err != nil {
+				return err
+			}
+
+			vm.popSchemaToken()
+			return nil
+		}
+
+		if err := vm.reportError(schema, "values"); err != nil {
+			return err
+		}
+
+		vm.popSchemaToken()
+	}
+
+	return stream.skip(token)
+}
+
+// evalStreamProperty validates the value of a member of an object against a
+// schema with the "properties" or "optionalProperties" keywords. The member's
+// name has already been read from stream.
+func (vm *vm) evalStreamProperty(schema *Schema, property string, stream *tokenStream) error {
+	required, isRequired := schema.Properties[property]
+	optional, isOptional := schema.OptionalProperties[property]
+
+	vm.pushInstanceToken(property)
+
+	switch {
+	case isRequired && isOptional:
+		// The value has to be validated twice, but can only be read once.
+		value, err := stream.decode()
+		if err != nil {
+			return err
+		}
+
+		vm.pushSchemaToken("properties")
+		vm.pushSchemaToken(property)
+		if err := vm.eval(required, value); err != nil {
+			return err
+		}
+		vm.popSchemaToken()
+		vm.popSchemaToken()
+
+		vm.pushSchemaToken("optionalProperties")
+		vm.pushSchemaToken(property)
+		if err := vm.eval(optional, value); err != nil {
+			return err
+		}
+		vm.popSchemaToken()
+		vm.popSchemaToken()
+	case isRequired:
+		vm.pushSchemaToken("properties")
+		vm.pushSchemaToken(property)
+		if err := vm.evalStream(required, stream); err != nil {
+			return err
+		}
+		vm.popSchemaToken()
+		vm.popSchemaToken()
+	case isOptional:
+		vm.pushSchemaToken("optionalProperties")
+		vm.pushSchemaToken(property)
+		if err := vm.evalStream(optional, stream); err != nil {
+			return err
+		}
+		vm.popSchemaToken()
+		vm.popSchemaToken()
+	default:
+		token, err := stream.token()
+		if err != nil {
+			return err
+		}
+
+		if err := stream.skip(token); err != nil {
+			return err
+		}
+
+		if !allowsAdditionalProperties(schema, vm.disallowAdditional) {
+			vm.pushSchemaToken("additionalProperties")
+			if err := vm.reportError(schema, "additionalProperties"); err != nil {
+				return err
+			}
+			vm.popSchemaToken()
+		}
+	}
+
+	vm.popInstanceToken()
+	return nil
+}
@@ -0,0 +1,200 @@
+package jsonvalidate
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sortErrors puts errors in a fixed order. ValidateDecoder reports errors in
+// the order the instance is read in, which is not the order Validate reports
+// them in.
+func sortErrors(errors []ValidationError) {
+	sort.Slice(errors, func(i, j int) bool {
+		a := errors[i]
+		b := errors[j]
+
+		if a.SchemaPath.String() == b.SchemaPath.String() {
+			return a.InstancePath.String() < b.InstancePath.String()
+		}
+
+		return a.SchemaPath.String() < b.SchemaPath.String()
+	})
+}
+
+func TestValidateReader(t *testing.T) {
+	testCases := []struct {
+		registry  []string
+		instances []string
+	}{
+		{
+			[]string{`{}`},
+			[]string{`null`, `[[]]`, `{"a":{"b":[1]}}`},
+		},
+		{
+			[]string{`{"type":"number"}`},
+			[]string{`null`, `1`, `"a"`, `[1,[2]]`, `{"a":{}}`},
+		},
+		{
+			[]string{`{"definitions":{"a":{"type":"string","nullable":true}},"elements":{"nullable":true,"ref":"#a"}}`},
+			[]string{`null`, `[null,"a",1]`},
+		},
+		{
+			[]string{`{"nullable":true,"discriminator":{"propertyName":"t","mapping":{"a":{"properties":{"x":{"type":"string","nullable":true}}}}}}`},
+			[]string{`null`, `{}`, `{"t":"a","x":null}`, `{"t":"a","x":1}`},
+		},
+		{
+			[]string{`{"nullable":true,"properties":{"a":{"values":{"nullable":true,"type":"number"}}}}`},
+			[]string{`null`, `{"a":null}`, `{"a":{"b":null,"c":1,"d":"e"}}`},
+		},
+		{
+			[]string{`{"properties":{"a":{}},"optionalProperties":{"b":{}},"additionalProperties":false}`},
+			[]string{`{"a":1}`, `{"a":1,"b":2,"c":3,"d":[4]}`},
+		},
+		{
+			[]string{`{"elements":{"discriminator":{"propertyName":"t","mapping":{"a":{"properties":{"x":{"additionalProperties":false,"properties":{}}},"additionalProperties":false}}}}}`},
+			[]string{`[{"t":"a","x":{}},{"t":"a","x":{"t":"a"},"y":1}]`},
+		},
+		{
+			[]string{`{"enum":["a","b"]}`},
+			[]string{`null`, `"a"`, `"c"`, `["a"]`, `{"a":"b"}`},
+		},
+		{
+			[]string{`{"elements":{"type":"string"}}`},
+			[]string{`null`, `{"a":[]}`, `[]`, `["a",1,"b",null,[1],{}]`},
+		},
+		{
+			[]string{`{"properties":{"a":{"type":"string"},"b":{}},"optionalProperties":{"c":{"type":"boolean"}}}`},
+			[]string{`null`, `[{}]`, `{}`, `{"a":1,"c":1}`, `{"a":"a","b":null,"c":true,"d":{"e":[]}}`},
+		},
+		{
+			[]string{`{"properties":{"a":{"type":"string"}},"optionalProperties":{"a":{"type":"number"}}}`},
+			[]string{`{}`, `{"a":1}`, `{"a":"a"}`},
+		},
+		{
+			[]string{`{"values":{"type":"null"}}`},
+			[]string{`[]`, `{}`, `{"a":1,"b":null,"c":true,"d":[null]}`},
+		},
+		{
+			[]string{`{"elements":{"discriminator":{"propertyName":"t","mapping":{"a":{"properties":{"x":{"type":"string"}}}}}}}`},
+			[]string{`[[], {}, {"t":1}, {"t":"b"}, {"x":1,"t":"a"}, {"t":"a","x":"x"}]`},
+		},
+		{
+			[]string{
+				`{"id":"http://example.com/bar","definitions":{"s":{"type":"string"}}}`,
+				`{"definitions":{"tree":{"properties":{"v":{"ref":"http://example.com/bar#s"},"c":{"elements":{"ref":"#tree"}}}}},"ref":"#tree"}`,
+			},
+			[]string{`{}`, `{"v":"a","c":[]}`, `{"v":"a","c":[{"v":1,"c":[{}]}]}`},
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			schemas := make([]SchemaStruct, len(tt.registry))
+			for i, s := range tt.registry {
+				assert.NoError(t, json.Unmarshal([]byte(s), &schemas[i]))
+			}
+
+			registry, err := NewRegistry(schemas)
+			assert.NoError(t, err)
+
+			validator := Validator{Registry: registry}
+			for _, s := range tt.instances {
+				var instance interface{}
+				assert.NoError(t, json.Unmarshal([]byte(s), &instance))
+
+				expected, err := validator.Validate(instance)
+				assert.NoError(t, err)
+
+				actual, err := validator.ValidateReader(strings.NewReader(s))
+				assert.NoError(t, err)
+
+				sortErrors(expected.Errors)
+				sortErrors(actual.Errors)
+				assert.Equal(t, expected, actual, s)
+			}
+		})
+	}
+}
+
+func TestValidateDecoderMaxErrors(t *testing.T) {
+	typeString := "string"
+	registry, err := NewRegistry([]SchemaStruct{
+		SchemaStruct{
+			Elements: &SchemaStruct{Type: &typeString},
+		},
+	})
+	assert.NoError(t, err)
+
+	// After stopping early, the rest of the first value must be skipped over so
+	// that the second value can be read.
+	decoder := json.NewDecoder(strings.NewReader(`[1, [2], {"a": [3]}] [4, "a"]`))
+	validator := Validator{MaxErrors: 1, Registry: registry}
+
+	result, err := validator.ValidateDecoder(url.URL{}, decoder)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "/0", result.Errors[0].InstancePath.String())
+
+	result, err = validator.ValidateDecoder(url.URL{}, decoder)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "/0", result.Errors[0].InstancePath.String())
+
+	_, err = validator.ValidateDecoder(url.URL{}, decoder)
+	assert.Equal(t, io.EOF, err)
+}
+
+// cancelingReader returns each of chunks in turn from Read, calling cancel
+// before it returns the second.
+type cancelingReader struct {
+	chunks []string
+	cancel context.CancelFunc
+	reads  int
+}
+
+func (r *cancelingReader) Read(p []byte) (int, error) {
+	if r.reads == len(r.chunks) {
+		return 0, io.EOF
+	}
+
+	if r.reads == 1 {
+		r.cancel()
+	}
+
+	n := copy(p, r.chunks[r.reads])
+	r.reads++
+	return n, nil
+}
+
+func TestValidateDecoderContext(t *testing.T) {
+	typeString := "string"
+	registry, err := NewRegistry([]SchemaStruct{
+		SchemaStruct{
+			Elements: &SchemaStruct{Type: &typeString},
+		},
+	})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+
+	// Validation stops partway through the value, and the errors found up to
+	// that point are kept.
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := &cancelingReader{chunks: []string{`[1, `, `2, 3, 4]`}, cancel: cancel}
+	result, err := validator.ValidateDecoderContext(ctx, url.URL{}, json.NewDecoder(reader))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "/0", result.Errors[0].InstancePath.String())
+
+	result, err = validator.ValidateDecoderContext(ctx, url.URL{}, json.NewDecoder(strings.NewReader(`[1]`)))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(result.Errors))
+}
@@ -0,0 +1,66 @@
+package jsonvalidate
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// timestampPattern is the "date-time" production of RFC 3339, section 5.6.
+var timestampPattern = regexp.MustCompile(
+	`^(\d{4})-(\d{2})-(\d{2})[Tt](\d{2}):(\d{2}):(\d{2})(?:\.\d+)?(?:[Zz]|([+-])(\d{2}):(\d{2}))$`,
+)
+
+// isTimestamp returns whether s is a valid RFC 3339 timestamp.
+//
+// Unlike time.Parse, this accepts leap seconds. Following RFC 3339, a leap
+// second is only valid if it falls on the last minute of a day in UTC, which
+// depends on the timestamp's offset.
+func isTimestamp(s string) bool {
+	m := timestampPattern.FindStringSubmatch(s)
+	if m == nil {
+		return false
+	}
+
+	// The pattern guarantees all of these are numbers.
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	hour, _ := strconv.Atoi(m[4])
+	minute, _ := strconv.Atoi(m[5])
+	second, _ := strconv.Atoi(m[6])
+
+	if month < 1 || month > 12 || day < 1 || day > daysIn(year, time.Month(month)) {
+		return false
+	}
+
+	if hour > 23 || minute > 59 || second > 60 {
+		return false
+	}
+
+	offset := 0
+	if m[7] != "" {
+		offsetHour, _ := strconv.Atoi(m[8])
+		offsetMinute, _ := strconv.Atoi(m[9])
+		if offsetHour > 23 || offsetMinute > 59 {
+			return false
+		}
+
+		offset = offsetHour*60 + offsetMinute
+		if m[7] == "-" {
+			offset = -offset
+		}
+	}
+
+	if second == 60 {
+		utc := time.Date(year, time.Month(month), day, hour, minute-offset, 0, 0, time.UTC)
+		return utc.Hour() == 23 && utc.Minute() == 59
+	}
+
+	return true
+}
+
+// daysIn returns the number of days in a month.
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
@@ -0,0 +1,47 @@
+package jsonvalidate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsTimestamp(t *testing.T) {
+	testCases := []struct {
+		in  string
+		out bool
+	}{
+		{"1985-04-12T23:20:50.52Z", true},
+		{"1996-12-19T16:39:57-08:00", true},
+		{"1937-01-01T12:00:27.87+00:20", true},
+		{"2019-01-01t00:00:00z", true},
+		{"2000-02-29T00:00:00Z", true},
+		{"1990-12-31T23:59:60Z", true},
+		{"1990-12-31T15:59:60-08:00", true},
+		{"1991-01-01T00:29:60+00:30", true},
+		{"1990-12-31T23:58:60Z", false},
+		{"1990-12-31T23:59:60+01:00", false},
+		{"1900-02-29T00:00:00Z", false},
+		{"2019-04-31T00:00:00Z", false},
+		{"2019-13-01T00:00:00Z", false},
+		{"2019-00-01T00:00:00Z", false},
+		{"2019-01-00T00:00:00Z", false},
+		{"2019-01-01T24:00:00Z", false},
+		{"2019-01-01T00:60:00Z", false},
+		{"2019-01-01T00:00:61Z", false},
+		{"2019-01-01T00:00:00+24:00", false},
+		{"2019-01-01T00:00:00+00:60", false},
+		{"2019-01-01T00:00:00", false},
+		{"2019-01-01 00:00:00Z", false},
+		{"2019-01-01T00:00:00.Z", false},
+		{"2019-01-01T00:00:00+0000", false},
+		{"2019-01-01", false},
+		{"", false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.in, func(t *testing.T) {
+			assert.Equal(t, tt.out, isTimestamp(tt.in))
+		})
+	}
+}
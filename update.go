@@ -0,0 +1,216 @@
+package jsonvalidate
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// Add returns a copy of r with schema added to it. It is an error for r to
+// already have a schema with the same "id" as schema.
+//
+// Like all of the methods which update a Registry, Add leaves r unchanged, so
+// validations which are using r may carry on while r is updated. Schemas
+// which are not affected by the update are shared between r and the returned
+// Registry. Only those which refer, directly or indirectly, to the schema
+// being updated have their references resolved again.
+//
+// If the update would leave a schema referring to a schema which doesn't
+// exist, it is fetched from the RegistryOptions.Resolver r was constructed
+// with, if any. If it can't be, the returned error is ErrMissingSchemas. If the
+// update would introduce a cycle of refs, the returned error is ErrRefCycle.
+func (r Registry) Add(schema SchemaStruct) (Registry, error) {
+	s, err := r.parse(schema)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	if _, ok := r.Schemas[*s.ID]; ok {
+		return Registry{}, fmt.Errorf("schema with uri already exists: %s", s.ID.String())
+	}
+
+	return r.update(map[url.URL]*Schema{*s.ID: s})
+}
+
+// Replace returns a copy of r in which the schema with the same "id" as schema
+// is replaced with schema. It is an error for r to not have such a schema.
+//
+// See Add for how r is updated.
+func (r Registry) Replace(schema SchemaStruct) (Registry, error) {
+	s, err := r.parse(schema)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	if _, ok := r.Schemas[*s.ID]; !ok {
+		return Registry{}, fmt.Errorf("no schema with uri: %s", s.ID.String())
+	}
+
+	return r.update(map[url.URL]*Schema{*s.ID: s})
+}
+
+// Remove returns a copy of r without the schema with the given URI. It is an
+// error for r to not have such a schema, or for any other schema to refer to
+// it.
+//
+// See Add for how r is updated.
+func (r Registry) Remove(uri url.URL) (Registry, error) {
+	if _, ok := r.Schemas[uri]; !ok {
+		return Registry{}, fmt.Errorf("no schema with uri: %s", uri.String())
+	}
+
+	return r.update(map[url.URL]*Schema{uri: nil})
+}
+
+// parse parses a schema to be added to r, using the options r was constructed
+// with.
+func (r Registry) parse(schema SchemaStruct) (*Schema, error) {
+	p := schemaParser{opts: r.opts}
+	if schema.ID != nil {
+		p.id = *schema.ID
+	}
+
+	s := p.parse([]string{}, true, schema)
+	if len(p.errors) > 0 {
+		return nil, SchemaErrors(p.errors)
+	}
+
+	return &s, nil
+}
+
+// update returns a copy of r in which each schema in changed replaces the one
+// with the same URI, or removes it if the schema in changed is nil.
+func (r Registry) update(changed map[url.URL]*Schema) (Registry, error) {
+	// Every schema which refers to a changed schema, whether directly or
+	// through other schemas, holds pointers into the old version of it. Those
+	// schemas are copied and have their references resolved again. The rest
+	// can be shared.
+	referrers := map[url.URL][]url.URL{}
+	for uri, schema := range r.Schemas {
+		targets := map[url.URL]bool{}
+		refTargets(targets, schema)
+
+		for target := range targets {
+			referrers[target] = append(referrers[target], uri)
+		}
+	}
+
+	affected := map[url.URL]bool{}
+	queue := make([]url.URL, 0, len(changed))
+	for uri := range changed {
+		queue = append(queue, uri)
+	}
+
+	for len(queue) > 0 {
+		uri := queue[0]
+		queue = queue[1:]
+
+		if !affected[uri] {
+			affected[uri] = true
+			queue = append(queue, referrers[uri]...)
+		}
+	}
+
+	schemas := make(map[url.URL]*Schema, len(r.Schemas)+len(changed))
+	for uri, schema := range r.Schemas {
+		schemas[uri] = schema
+	}
+
+	resolve := map[url.URL]*Schema{}
+	for uri := range affected {
+		schema, ok := changed[uri]
+		if !ok {
+			schema = copySchema(r.Schemas[uri])
+		}
+
+		if schema == nil {
+			delete(schemas, uri)
+			continue
+		}
+
+		schemas[uri] = schema
+		resolve[uri] = schema
+	}
+
+	uris := make([]url.URL, 0, len(resolve))
+	for uri := range resolve {
+		uris = append(uris, uri)
+	}
+
+	// Sort, so that the same update always produces the same error.
+	sort.Slice(uris, func(i, j int) bool {
+		return uris[i].String() < uris[j].String()
+	})
+
+	missingURIs := []url.URL{}
+	for _, uri := range uris {
+		populateSchemaRefs(&missingURIs, schemas, resolve[uri].ID, resolve[uri])
+	}
+
+	if len(missingURIs) > 0 && r.opts.Resolver != nil {
+		var err error
+		if missingURIs, err = resolveSchemas(schemas, resolve, missingURIs, r.opts); err != nil {
+			return Registry{}, err
+		}
+	}
+
+	if len(missingURIs) > 0 {
+		return Registry{}, missingSchemas(missingURIs)
+	}
+
+	// Any new cycle has to pass through one of the schemas which changed.
+	if cycle := findRefCycle(resolve); cycle != nil {
+		return Registry{}, ErrRefCycle{URIs: cycle}
+	}
+
+	return Registry{Schemas: schemas, opts: r.opts}, nil
+}
+
+// refTargets adds to targets the URI of each root schema which schema, or any
+// of its sub-schemas, refers to.
+func refTargets(targets map[url.URL]bool, schema *Schema) {
+	if schema.Ref != nil {
+		uri := schema.Base.ResolveReference(schema.Ref)
+		uri.Fragment = ""
+		targets[*uri] = true
+	}
+
+	for _, subSchema := range subSchemas(schema) {
+		refTargets(targets, subSchema)
+	}
+}
+
+// copySchema returns a deep copy of schema, without its references resolved.
+// The parts of schema which are never modified once it's been parsed, such as
+// Enum and Extra, are shared with the copy.
+func copySchema(schema *Schema) *Schema {
+	out := *schema
+	out.RefSchema = nil
+	out.Definitions = copySchemaMap(schema.Definitions)
+	out.Properties = copySchemaMap(schema.Properties)
+	out.OptionalProperties = copySchemaMap(schema.OptionalProperties)
+	out.DiscriminatorMapping = copySchemaMap(schema.DiscriminatorMapping)
+
+	if schema.Elements != nil {
+		out.Elements = copySchema(schema.Elements)
+	}
+
+	if schema.Values != nil {
+		out.Values = copySchema(schema.Values)
+	}
+
+	return &out
+}
+
+func copySchemaMap(schemas map[string]*Schema) map[string]*Schema {
+	if schemas == nil {
+		return nil
+	}
+
+	out := make(map[string]*Schema, len(schemas))
+	for k, v := range schemas {
+		out[k] = copySchema(v)
+	}
+
+	return out
+}
@@ -0,0 +1,185 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// unmarshalSchema decodes a SchemaStruct from JSON, failing the test if it
+// can't be.
+func unmarshalSchema(t *testing.T, s string) SchemaStruct {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(s), &schema))
+	return schema
+}
+
+func TestRegistryUpdate(t *testing.T) {
+	a := url.URL{Scheme: "http", Host: "example.com", Path: "/a"}
+	b := url.URL{Scheme: "http", Host: "example.com", Path: "/b"}
+	c := url.URL{Scheme: "http", Host: "example.com", Path: "/c"}
+
+	registry, err := NewRegistry([]SchemaStruct{
+		unmarshalSchema(t, `{"properties":{"a":{"ref":"http://example.com/a"}}}`),
+		unmarshalSchema(t, `{"id":"http://example.com/a","elements":{"ref":"#x"},"definitions":{"x":{"ref":"b"}}}`),
+		unmarshalSchema(t, `{"id":"http://example.com/b","type":"string"}`),
+	})
+	assert.NoError(t, err)
+
+	isValid := func(registry Registry, instance string) bool {
+		var decoded interface{}
+		assert.NoError(t, json.Unmarshal([]byte(instance), &decoded))
+
+		result, err := Validator{Registry: registry}.Validate(decoded)
+		assert.NoError(t, err)
+		return result.IsValid()
+	}
+
+	assert.True(t, isValid(registry, `{"a":["x"]}`))
+	assert.False(t, isValid(registry, `{"a":[1]}`))
+
+	// Replacing a schema updates every schema which refers to it, and leaves
+	// the original registry as it was.
+	replaced, err := registry.Replace(unmarshalSchema(t, `{"id":"http://example.com/b","type":"number"}`))
+	assert.NoError(t, err)
+	assert.True(t, isValid(registry, `{"a":["x"]}`))
+	assert.False(t, isValid(replaced, `{"a":["x"]}`))
+	assert.True(t, isValid(replaced, `{"a":[1]}`))
+	assert.True(t, registry.Schemas[url.URL{}] != replaced.Schemas[url.URL{}])
+	assert.True(t, registry.Schemas[a] != replaced.Schemas[a])
+
+	// Schemas which don't refer to the changed schema are shared.
+	added, err := replaced.Add(unmarshalSchema(t, `{"id":"http://example.com/c","ref":"b"}`))
+	assert.NoError(t, err)
+	assert.True(t, replaced.Schemas[url.URL{}] == added.Schemas[url.URL{}])
+	assert.True(t, replaced.Schemas[a] == added.Schemas[a])
+	assert.True(t, replaced.Schemas[b] == added.Schemas[b])
+	assert.Equal(t, 4, len(added.Schemas))
+	assert.Equal(t, 3, len(replaced.Schemas))
+
+	_, err = added.Add(unmarshalSchema(t, `{"id":"http://example.com/c"}`))
+	assert.Equal(t, "schema with uri already exists: http://example.com/c", err.Error())
+
+	_, err = added.Replace(unmarshalSchema(t, `{"id":"http://example.com/d"}`))
+	assert.Equal(t, "no schema with uri: http://example.com/d", err.Error())
+
+	_, err = added.Add(unmarshalSchema(t, `{"id":"http://example.com/d","type":"foo"}`))
+	assert.Equal(t, SchemaErrorCodeInvalidType, err.(SchemaErrors)[0].Code)
+
+	// Updates which would leave refs dangling, or loop forever, are rejected.
+	_, err = added.Add(unmarshalSchema(t, `{"id":"http://example.com/d","ref":"e"}`))
+	assert.Equal(t, ErrMissingSchemas{URIs: []url.URL{url.URL{Scheme: "http", Host: "example.com", Path: "/e"}}}, err)
+
+	_, err = added.Remove(b)
+	assert.Equal(t, ErrMissingSchemas{URIs: []url.URL{b}}, err)
+
+	_, err = added.Replace(unmarshalSchema(t, `{"id":"http://example.com/b","ref":"c"}`))
+	assert.Equal(t, ErrRefCycle{URIs: []url.URL{c, b}}, err)
+
+	removed, err := added.Remove(c)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(removed.Schemas))
+	assert.Equal(t, 4, len(added.Schemas))
+
+	_, err = removed.Remove(c)
+	assert.Equal(t, "no schema with uri: http://example.com/c", err.Error())
+}
+
+// TestRegistryUpdateConcurrent validates against a registry while it is being
+// updated. It is only meaningful when run with -race.
+func TestRegistryUpdateConcurrent(t *testing.T) {
+	registry, err := NewRegistry([]SchemaStruct{
+		unmarshalSchema(t, `{"properties":{"a":{"ref":"http://example.com/a"}}}`),
+		unmarshalSchema(t, `{"id":"http://example.com/a","elements":{"ref":"#x"},"definitions":{"x":{"ref":"b"}}}`),
+		unmarshalSchema(t, `{"id":"http://example.com/b","type":"string"}`),
+	})
+	assert.NoError(t, err)
+
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	instance := map[string]interface{}{"a": []interface{}{"x", 1.0}}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				result, err := Validator{Registry: registry}.Validate(instance)
+				assert.NoError(t, err)
+				assert.Equal(t, 1, len(result.Errors))
+
+				result, err = program.Validate(instance)
+				assert.NoError(t, err)
+				assert.Equal(t, 1, len(result.Errors))
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		replaced, err := registry.Replace(unmarshalSchema(t, `{"id":"http://example.com/b","type":"number"}`))
+		assert.NoError(t, err)
+
+		_, err = replaced.Add(unmarshalSchema(t, `{"id":"http://example.com/c","ref":"a#x"}`))
+		assert.NoError(t, err)
+
+		_, err = registry.Add(unmarshalSchema(t, `{"id":"http://example.com/c","ref":"b"}`))
+		assert.NoError(t, err)
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+func TestRegistryUpdateOptions(t *testing.T) {
+	registry, err := NewRegistryWithOptions(nil, RegistryOptions{Strict: true})
+	assert.NoError(t, err)
+
+	_, err = registry.Add(unmarshalSchema(t, `{"foo":1}`))
+	assert.Equal(t, SchemaErrorCodeUnknownKeyword, err.(SchemaErrors)[0].Code)
+
+	registry, err = Registry{}.Add(unmarshalSchema(t, `{"foo":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(registry.Schemas))
+}
+
+func TestRegistryUpdateResolver(t *testing.T) {
+	resolver := MapResolver{
+		url.URL{Scheme: "http", Host: "example.com", Path: "/a"}: unmarshalSchema(t, `{"ref":"b"}`),
+		url.URL{Scheme: "http", Host: "example.com", Path: "/b"}: unmarshalSchema(t, `{"type":"string"}`),
+	}
+
+	registry, err := NewRegistryWithOptions(nil, RegistryOptions{Resolver: resolver})
+	assert.NoError(t, err)
+
+	// Schemas which an added schema refers to are fetched, just as they are
+	// when the registry is constructed.
+	added, err := registry.Add(unmarshalSchema(t, `{"elements":{"ref":"http://example.com/a"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(registry.Schemas))
+	assert.Equal(t, 3, len(added.Schemas))
+
+	result, err := Validator{Registry: added}.Validate([]interface{}{"x", 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+
+	replaced, err := added.Replace(unmarshalSchema(t, `{"values":{"ref":"http://example.com/c"}}`))
+	assert.Equal(t, ErrMissingSchemas{URIs: []url.URL{{Scheme: "http", Host: "example.com", Path: "/c"}}}, err)
+	assert.Equal(t, Registry{}, replaced)
+
+	_, err = Registry{}.Add(unmarshalSchema(t, `{"ref":"http://example.com/a"}`))
+	_, ok := err.(ErrMissingSchemas)
+	assert.True(t, ok)
+}
@@ -1,6 +1,7 @@
 package jsonvalidate
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/url"
@@ -8,12 +9,54 @@ import (
 	"github.com/json-validate/json-pointer-go"
 )
 
+// Unlimited, as the value of a Validator's MaxErrors, MaxDepth or
+// MaxInstanceDepth, disables that limit. Any negative value has the same
+// effect.
+const Unlimited = -1
+
+// The limits which a Validator uses when the corresponding field is zero.
+const (
+	DefaultMaxErrors        = 1000
+	DefaultMaxDepth         = 256
+	DefaultMaxInstanceDepth = 256
+)
+
 type Validator struct {
+	// The most errors to report. Once this many errors have been found,
+	// validation stops early. Zero means DefaultMaxErrors.
 	MaxErrors int
-	MaxDepth  int
-	Registry  Registry
+
+	// The most refs which may be followed within one another. Validation fails
+	// with ErrMaxDepth if a schema recurses deeper than this. Zero means
+	// DefaultMaxDepth.
+	MaxDepth int
+
+	// The most arrays and objects which may be nested within one another in
+	// the parts of an instance which are validated. Validation fails with
+	// ErrMaxInstanceDepth if an instance nests deeper than this. Zero means
+	// DefaultMaxInstanceDepth.
+	MaxInstanceDepth int
+
+	Registry Registry
+
+	// StrictNumbers, if true, makes the "number" type reject numbers which JSON
+	// cannot represent: json.Number values which are not valid JSON numbers or
+	// which overflow a float64, and floats which are NaN or infinite.
+	StrictNumbers bool
+
+	// DisallowAdditionalProperties, if true, makes schemas with the
+	// "properties" or "optionalProperties" keywords reject properties they do
+	// not declare, unless the schema sets "additionalProperties" to true.
+	DisallowAdditionalProperties bool
 }
 
+// ValidationResult is the outcome of validating an instance.
+//
+// Errors are in a deterministic order, so that the same instance always
+// produces the same errors, and MaxErrors always keeps the same ones. Elements
+// of arrays are checked in order, and properties of objects in sorted order of
+// their names; Validator.ValidateDecoder instead checks properties in the order
+// they appear in the input.
 type ValidationResult struct {
 	Errors []ValidationError `json:"errors"`
 }
@@ -26,6 +69,19 @@ type ValidationError struct {
 	InstancePath jsonpointer.Ptr
 	SchemaPath   jsonpointer.Ptr
 	SchemaURI    url.URL
+
+	// Where in the source of the instance the value at InstancePath begins.
+	// This is only set by ValidationResult.AddPositions.
+	Position *Position
+
+	// A message for the error configured in the schema, using the keyword
+	// named by ErrorMessageKeyword. It is set when the error is reported.
+	CustomMessage string
+
+	// What the problem is, in a form a MessageCatalog can describe. This is
+	// only set by ValidationError.Explain, and is nil if the error couldn't be
+	// explained.
+	Explanation *Explanation
 }
 
 func (e *ValidationError) UnmarshalJSON(data []byte) error {
@@ -64,35 +120,75 @@ func (e *ValidationError) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Validate validates instance against the default schema, which is the schema
+// without an "id".
+//
+// instance need not be the output of decoding JSON into an interface{}. Any Go
+// value which encoding/json can marshal may be validated, and it is treated
+// exactly as its JSON encoding would be: struct tags, "omitempty", embedded
+// structs, pointers and json.Marshaler implementations are all honored.
 func (v Validator) Validate(instance interface{}) (ValidationResult, error) {
 	return v.ValidateURI(url.URL{}, instance)
 }
 
 func (v Validator) ValidateURI(uri url.URL, instance interface{}) (ValidationResult, error) {
+	return v.ValidateContext(context.Background(), uri, instance)
+}
+
+// ValidateContext is like ValidateURI, but stops validating once ctx is done.
+// In that case, it returns the errors found so far, along with ctx.Err().
+func (v Validator) ValidateContext(ctx context.Context, uri url.URL, instance interface{}) (ValidationResult, error) {
 	schema, ok := v.Registry.Schemas[uri]
 	if !ok {
 		return ValidationResult{}, fmt.Errorf("no schema with uri: %s", uri.String())
 	}
 
-	vm := vm{
-		maxErrors:      v.MaxErrors,
-		maxDepth:       v.MaxDepth,
-		registry:       v.Registry,
-		instanceTokens: []string{},
+	vm := v.newVM(&uri)
+	vm.done = ctx.Done()
+	if err := vm.eval(schema, instance); err != nil {
+		if err == errDone {
+			return ValidationResult{Errors: vm.errors}, ctx.Err()
+		}
+
+		if err != errMaxErrors {
+			return ValidationResult{}, err
+		}
+	}
+
+	return ValidationResult{Errors: vm.errors}, nil
+}
+
+func (v Validator) newVM(uri *url.URL) vm {
+	return vm{
+		maxErrors:          limit(v.MaxErrors, DefaultMaxErrors),
+		maxDepth:           limit(v.MaxDepth, DefaultMaxDepth),
+		maxInstanceDepth:   limit(v.MaxInstanceDepth, DefaultMaxInstanceDepth),
+		strictNumbers:      v.StrictNumbers,
+		disallowAdditional: v.DisallowAdditionalProperties,
+		registry:           v.Registry,
+		instanceTokens:     []string{},
 		schemas: []schemaStack{
 			schemaStack{
-				uri:    &uri,
+				uri:    uri,
 				tokens: []string{},
 			},
 		},
 		errors: []ValidationError{},
 	}
+}
 
-	if err := vm.eval(schema, instance); err != nil {
-		if err != errMaxErrors {
-			return ValidationResult{}, err
-		}
+// limit returns the limit to use for n, one of a Validator's limits: def if n
+// is zero, and Unlimited if n is negative. Because the depth and number of
+// errors only ever grow one at a time, and never become negative, a limit of
+// Unlimited is never reached.
+func limit(n, def int) int {
+	if n == 0 {
+		return def
 	}
 
-	return ValidationResult{Errors: vm.errors}, nil
+	if n < 0 {
+		return Unlimited
+	}
+
+	return n
 }
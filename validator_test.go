@@ -0,0 +1,534 @@
+package jsonvalidate
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/json-validate/json-pointer-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateNumbers(t *testing.T) {
+	typeNumber := "number"
+	registry, err := NewRegistry([]SchemaStruct{
+		SchemaStruct{Type: &typeNumber},
+	})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		instance interface{}
+		valid    bool
+		strict   bool
+	}{
+		{1.5, true, true},
+		{float32(1.5), true, true},
+		{int(-1), true, true},
+		{int8(-1), true, true},
+		{int16(-1), true, true},
+		{int32(-1), true, true},
+		{int64(-1), true, true},
+		{uint(1), true, true},
+		{uint8(1), true, true},
+		{uint16(1), true, true},
+		{uint32(1), true, true},
+		{uint64(math.MaxUint64), true, true},
+		{json.Number("9007199254740993"), true, true},
+		{json.Number("-1.5e10"), true, true},
+		{json.Number("1e400"), true, false},
+		{json.Number("NaN"), true, false},
+		{json.Number("0x10"), true, false},
+		{json.Number(""), true, false},
+		{math.NaN(), true, false},
+		{math.Inf(-1), true, false},
+		{float32(math.Inf(1)), true, false},
+		{"1", false, false},
+		{nil, false, false},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			for _, strict := range []bool{false, true} {
+				validator := Validator{Registry: registry, StrictNumbers: strict}
+				program, err := registry.Compile(url.URL{})
+				assert.NoError(t, err)
+				program.StrictNumbers = strict
+
+				valid := tt.valid
+				if strict {
+					valid = tt.strict
+				}
+
+				result, err := validator.Validate(tt.instance)
+				assert.NoError(t, err)
+				assert.Equal(t, valid, result.IsValid())
+
+				result, err = program.Validate(tt.instance)
+				assert.NoError(t, err)
+				assert.Equal(t, valid, result.IsValid())
+			}
+		})
+	}
+}
+
+func TestValidateDecoderUseNumber(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{"elements":{"type":"number"}}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	decoder := json.NewDecoder(strings.NewReader(`[1, 18446744073709551615, 1e400]`))
+	decoder.UseNumber()
+
+	validator := Validator{Registry: registry, StrictNumbers: true}
+	result, err := validator.ValidateDecoder(url.URL{}, decoder)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "/2", result.Errors[0].InstancePath.String())
+}
+
+func TestValidateSizedNumbers(t *testing.T) {
+	testCases := []struct {
+		typ     string
+		valid   []interface{}
+		invalid []interface{}
+	}{
+		{
+			"int8",
+			[]interface{}{-128.0, 127.0, 1.0, int64(-128), json.Number("127"), json.Number("1.0")},
+			[]interface{}{-129.0, 128.0, 1.5, json.Number("128"), json.Number("1e400"), "1", nil},
+		},
+		{
+			"uint8",
+			[]interface{}{0.0, 255.0, uint8(255), json.Number("0")},
+			[]interface{}{-1.0, 256.0, 0.5, int(-1)},
+		},
+		{
+			"int16",
+			[]interface{}{-32768.0, 32767.0},
+			[]interface{}{-32769.0, 32768.0, 1.5},
+		},
+		{
+			"uint16",
+			[]interface{}{0.0, 65535.0},
+			[]interface{}{-1.0, 65536.0, 1.5},
+		},
+		{
+			"int32",
+			[]interface{}{-2147483648.0, 2147483647.0, int32(math.MinInt32)},
+			[]interface{}{-2147483649.0, 2147483648.0, 1.5},
+		},
+		{
+			"uint32",
+			[]interface{}{0.0, 4294967295.0, uint64(math.MaxUint32)},
+			[]interface{}{-1.0, 4294967296.0, uint64(math.MaxUint64), 1.5},
+		},
+		{
+			"float32",
+			[]interface{}{1.5, -3.4e38, float32(math.MaxFloat32), json.Number("1e38")},
+			[]interface{}{3.5e38, math.Inf(1), json.Number("1e39"), "1.5"},
+		},
+		{
+			"float64",
+			[]interface{}{1.5, 1e300, float32(1.5), json.Number("-1e308")},
+			[]interface{}{math.NaN(), math.Inf(-1), json.Number("1e400"), json.Number("Inf"), true},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.typ, func(t *testing.T) {
+			typ := tt.typ
+			registry, err := NewRegistry([]SchemaStruct{
+				SchemaStruct{Type: &typ},
+			})
+			assert.NoError(t, err)
+
+			validator := Validator{Registry: registry}
+			program, err := registry.Compile(url.URL{})
+			assert.NoError(t, err)
+
+			for _, instance := range tt.valid {
+				result, err := validator.Validate(instance)
+				assert.NoError(t, err)
+				assert.True(t, result.IsValid(), "%#v", instance)
+
+				result, err = program.Validate(instance)
+				assert.NoError(t, err)
+				assert.True(t, result.IsValid(), "%#v", instance)
+			}
+
+			for _, instance := range tt.invalid {
+				result, err := validator.Validate(instance)
+				assert.NoError(t, err)
+				assert.Equal(t, []ValidationError{
+					ValidationError{
+						InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+						SchemaPath:   jsonpointer.Ptr{Tokens: []string{"type"}},
+					},
+				}, result.Errors, "%#v", instance)
+
+				result, err = program.Validate(instance)
+				assert.NoError(t, err)
+				assert.False(t, result.IsValid(), "%#v", instance)
+			}
+		})
+	}
+}
+
+func TestValidateTimestamp(t *testing.T) {
+	typeTimestamp := "timestamp"
+	registry, err := NewRegistry([]SchemaStruct{
+		SchemaStruct{Type: &typeTimestamp},
+	})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		instance interface{}
+		valid    bool
+	}{
+		{"2019-01-01T00:00:00Z", true},
+		{"2016-12-31T23:59:60Z", true},
+		{"2019-01-01", false},
+		{1546300800.0, false},
+		{nil, false},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			result, err := validator.Validate(tt.instance)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, result.IsValid())
+
+			result, err = program.Validate(tt.instance)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.valid, result.IsValid())
+		})
+	}
+}
+
+func TestValidateEnum(t *testing.T) {
+	registry, err := NewRegistry([]SchemaStruct{
+		SchemaStruct{
+			Enum: &[]string{"pending", "active", "closed"},
+		},
+	})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+	for _, instance := range []interface{}{"pending", "active", "closed"} {
+		result, err := validator.Validate(instance)
+		assert.NoError(t, err)
+		assert.True(t, result.IsValid())
+	}
+
+	for _, instance := range []interface{}{"Pending", "", 1.0, nil, []interface{}{"active"}} {
+		result, err := validator.Validate(instance)
+		assert.NoError(t, err)
+		assert.Equal(t, []ValidationError{
+			ValidationError{
+				InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+				SchemaPath:   jsonpointer.Ptr{Tokens: []string{"enum"}},
+			},
+		}, result.Errors)
+	}
+}
+
+func TestValidateNullable(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"a": {"type": "string", "nullable": true},
+			"b": {"type": "string"},
+			"c": {"properties": {}, "nullable": true},
+			"d": {"enum": ["x"], "nullable": true},
+			"e": {"elements": {}, "nullable": true}
+		}
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+	result, err := validator.Validate(map[string]interface{}{
+		"a": nil,
+		"b": nil,
+		"c": nil,
+		"d": nil,
+		"e": (*[]string)(nil),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{"b"}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"properties", "b", "type"}},
+		},
+	}, result.Errors)
+}
+
+func TestValidateAdditionalProperties(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"a": {},
+			"loose": {"properties": {}, "additionalProperties": true},
+			"event": {
+				"discriminator": {
+					"propertyName": "type",
+					"mapping": {
+						"click": {"properties": {"x": {"properties": {}}}}
+					}
+				}
+			}
+		}
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	instance := `{
+		"a": 1,
+		"emial": 2,
+		"loose": {"b": 3},
+		"event": {"type": "click", "x": {"type": 4}, "y": 5}
+	}`
+
+	expected := []ValidationError{
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{"event", "x", "type"}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"properties", "event", "discriminator", "mapping", "click", "properties", "x", "additionalProperties"}},
+		},
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{"event", "y"}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"properties", "event", "discriminator", "mapping", "click", "additionalProperties"}},
+		},
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{"emial"}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"additionalProperties"}},
+		},
+	}
+
+	var decoded interface{}
+	assert.NoError(t, json.Unmarshal([]byte(instance), &decoded))
+
+	validator := Validator{Registry: registry}
+	result, err := validator.Validate(decoded)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	validator.DisallowAdditionalProperties = true
+	result, err = validator.Validate(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result.Errors)
+
+	// ValidateReader reports errors in the order the instance is read in.
+	result, err = validator.ValidateReader(strings.NewReader(instance))
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{expected[2], expected[0], expected[1]}, result.Errors)
+
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+	program.DisallowAdditionalProperties = true
+
+	result, err = program.Validate(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result.Errors)
+}
+
+func TestValidateErrorOrder(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"e": {}, "d": {}, "c": {},
+			"v": {"values": {"type": "string"}}
+		},
+		"optionalProperties": {
+			"b": {"type": "string"}, "a": {"type": "string"}
+		},
+		"additionalProperties": false
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	instance := `{"z": 1, "a": 1, "y": 1, "b": 1, "v": {"q": 1, "p": 1, "r": 1}}`
+	var decoded interface{}
+	assert.NoError(t, json.Unmarshal([]byte(instance), &decoded))
+
+	paths := func(errors []ValidationError) []string {
+		out := make([]string, len(errors))
+		for i, err := range errors {
+			out[i] = err.InstancePath.String() + " " + err.SchemaPath.String()
+		}
+
+		return out
+	}
+
+	sorted := []string{
+		" /properties/c",
+		" /properties/d",
+		" /properties/e",
+		"/v/p /properties/v/values/type",
+		"/v/q /properties/v/values/type",
+		"/v/r /properties/v/values/type",
+		"/a /optionalProperties/a/type",
+		"/b /optionalProperties/b/type",
+		"/y /additionalProperties",
+		"/z /additionalProperties",
+	}
+
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	// Map iteration order is random, so check several times over.
+	for i := 0; i < 10; i++ {
+		result, err := Validator{Registry: registry}.Validate(decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, sorted, paths(result.Errors))
+
+		result, err = program.Validate(decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, sorted, paths(result.Errors))
+
+		result, err = Validator{MaxErrors: 4, Registry: registry}.Validate(decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, sorted[:4], paths(result.Errors))
+
+		result, err = Validator{Registry: registry}.ValidateReader(strings.NewReader(instance))
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"/z /additionalProperties",
+			"/a /optionalProperties/a/type",
+			"/y /additionalProperties",
+			"/b /optionalProperties/b/type",
+			"/v/q /properties/v/values/type",
+			"/v/p /properties/v/values/type",
+			"/v/r /properties/v/values/type",
+			" /properties/c",
+			" /properties/d",
+			" /properties/e",
+		}, paths(result.Errors))
+	}
+}
+
+// cancelingValue cancels a context when it is converted to JSON.
+type cancelingValue struct {
+	cancel context.CancelFunc
+}
+
+func (v cancelingValue) MarshalJSON() ([]byte, error) {
+	v.cancel()
+	return []byte("1"), nil
+}
+
+func TestValidateContext(t *testing.T) {
+	typeString := "string"
+	registry, err := NewRegistry([]SchemaStruct{
+		SchemaStruct{
+			Elements: &SchemaStruct{Type: &typeString},
+		},
+	})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result, err := validator.ValidateContext(ctx, url.URL{}, []interface{}{1, 2, 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(result.Errors))
+
+	// Validation stops at the element after the one which cancels ctx, and
+	// the errors found up to that point are kept.
+	instance := []interface{}{1, cancelingValue{cancel}, 3, 4}
+	result, err = validator.ValidateContext(ctx, url.URL{}, instance)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 2, len(result.Errors))
+	assert.Equal(t, "/1", result.Errors[1].InstancePath.String())
+
+	result, err = validator.ValidateContext(ctx, url.URL{}, []interface{}{1})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, len(result.Errors))
+}
+
+func TestValidateLimits(t *testing.T) {
+	var nested SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"elements": {"values": {"elements": {"type": "string"}}}
+	}`), &nested))
+
+	var recursive SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"definitions": {"a": {"elements": {"ref": "#a"}}},
+		"ref": "#a"
+	}`), &recursive))
+
+	nestedRegistry, err := NewRegistry([]SchemaStruct{nested})
+	assert.NoError(t, err)
+
+	recursiveRegistry, err := NewRegistry([]SchemaStruct{recursive})
+	assert.NoError(t, err)
+
+	// Errors are limited by default, unless the limit is removed.
+	object := map[string]interface{}{}
+	for i := 0; i < DefaultMaxErrors+10; i++ {
+		object[strconv.Itoa(i)] = []interface{}{1}
+	}
+
+	many := []interface{}{object}
+	result, err := Validator{Registry: nestedRegistry}.Validate(many)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMaxErrors, len(result.Errors))
+
+	result, err = Validator{MaxErrors: Unlimited, Registry: nestedRegistry}.Validate(many)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultMaxErrors+10, len(result.Errors))
+
+	// Instance depth and ref depth are limited separately.
+	deep := `[{"a": [[]]}]`
+	var decoded interface{}
+	assert.NoError(t, json.Unmarshal([]byte(deep), &decoded))
+
+	validator := Validator{Registry: nestedRegistry, MaxInstanceDepth: 2}
+	_, err = validator.Validate(decoded)
+	assert.Equal(t, ErrMaxInstanceDepth, err)
+
+	_, err = validator.ValidateReader(strings.NewReader(deep))
+	assert.Equal(t, ErrMaxInstanceDepth, err)
+
+	program, err := nestedRegistry.Compile(url.URL{})
+	assert.NoError(t, err)
+	program.MaxInstanceDepth = 2
+	_, err = program.Validate(decoded)
+	assert.Equal(t, ErrMaxInstanceDepth, err)
+
+	validator.MaxInstanceDepth = 3
+	result, err = validator.Validate(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+
+	var tree interface{} = []interface{}{}
+	for i := 0; i < 2*DefaultMaxDepth; i++ {
+		tree = []interface{}{tree}
+	}
+
+	validator = Validator{Registry: recursiveRegistry}
+	_, err = validator.Validate(tree)
+	assert.Equal(t, ErrMaxDepth, err)
+
+	validator.MaxDepth = Unlimited
+	_, err = validator.Validate(tree)
+	assert.Equal(t, ErrMaxInstanceDepth, err)
+
+	validator.MaxInstanceDepth = Unlimited
+	result, err = validator.Validate(tree)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+}
@@ -0,0 +1,411 @@
+package jsonvalidate
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+var arrayType = reflect.TypeOf([]interface{}{})
+var objectType = reflect.TypeOf(map[string]interface{}{})
+var numberType = reflect.TypeOf(json.Number(""))
+
+// jsonValue converts an arbitrary Go value into the form encoding/json produces
+// when decoding into an interface{}: nil, bool, float64, string,
+// []interface{}, or map[string]interface{}. The one exception is numbers: values
+// of any of Go's numeric types, as well as json.Number, are returned as-is, and
+// those of named numeric types as an int64, uint64 or float64.
+//
+// The conversion follows the rules of json.Marshal, so struct tags, embedded
+// structs, pointers, and json.Marshaler are all honored. Only the outermost
+// level of v is converted; the elements of the returned arrays and objects are
+// converted when they in turn are passed to jsonValue. That way, values which
+// are already in the right form, which is by far the most common case, are not
+// copied.
+func jsonValue(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case nil, bool, string, []interface{}, map[string]interface{}:
+		return v, nil
+	case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return v, nil
+	case json.Number:
+		return v, nil
+	}
+
+	return reflectValue(reflect.ValueOf(v))
+}
+
+func reflectValue(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	// json.Number is a string as far as reflection is concerned, so it has
+	// to be recognized before its kind is looked at.
+	switch v.Type() {
+	case arrayType, objectType, numberType:
+		return v.Interface(), nil
+	}
+
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(v.Type()).Implements(marshalerType) {
+		v = v.Addr()
+	}
+
+	if v.Type().Implements(marshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, nil
+		}
+
+		data, err := v.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		var out interface{}
+		err = json.Unmarshal(data, &out)
+		return out, err
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, nil
+		}
+
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	// Converting integers to float64 would lose precision above 2^53.
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		return reflectValue(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		// Like encoding/json, treat []byte as a base64-encoded string.
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+
+		return reflectElements(v), nil
+	case reflect.Array:
+		return reflectElements(v), nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		return reflectMap(v)
+	case reflect.Struct:
+		return reflectStruct(v)
+	}
+
+	// Channels, functions, and the like have no JSON representation. Return
+	// them as-is, so that they fail every check.
+	return v.Interface(), nil
+}
+
+// elementValue returns a value which can later be passed to jsonValue. When
+// possible, this is a pointer to v, so that methods on *T are found and large
+// structs are not copied.
+func elementValue(v reflect.Value) interface{} {
+	if v.CanAddr() {
+		return v.Addr().Interface()
+	}
+
+	return v.Interface()
+}
+
+func reflectElements(v reflect.Value) []interface{} {
+	out := make([]interface{}, v.Len())
+	for i := range out {
+		out[i] = elementValue(v.Index(i))
+	}
+
+	return out
+}
+
+func reflectMap(v reflect.Value) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, v.Len())
+	for _, k := range v.MapKeys() {
+		key, err := reflectMapKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		out[key] = v.MapIndex(k).Interface()
+	}
+
+	return out, nil
+}
+
+func reflectMapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+
+	return "", &json.UnsupportedTypeError{Type: k.Type()}
+}
+
+func reflectStruct(v reflect.Value) (map[string]interface{}, error) {
+	fields := cachedStructFields(v.Type())
+	out := make(map[string]interface{}, len(fields))
+
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		if f.quoted {
+			value, err := quotedValue(fv)
+			if err != nil {
+				return nil, err
+			}
+
+			out[f.name] = value
+			continue
+		}
+
+		out[f.name] = elementValue(fv)
+	}
+
+	return out, nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, except that it returns false
+// instead of panicking if the field is promoted through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+
+			v = v.Elem()
+		}
+
+		v = v.Field(x)
+	}
+
+	return v, true
+}
+
+// quotedValue handles fields with the ",string" option, which encoding/json
+// encodes as a JSON string containing the field's usual encoding.
+func quotedValue(v reflect.Value) (interface{}, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		v = v.Elem()
+	}
+
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	return string(data), nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
+
+// structField is a field of a struct, as seen by encoding/json.
+type structField struct {
+	name      string
+	tagged    bool
+	index     []int
+	omitEmpty bool
+	quoted    bool
+}
+
+var structFieldCache sync.Map // map[reflect.Type][]structField
+
+func cachedStructFields(t reflect.Type) []structField {
+	if fields, ok := structFieldCache.Load(t); ok {
+		return fields.([]structField)
+	}
+
+	fields, _ := structFieldCache.LoadOrStore(t, structFields(t))
+	return fields.([]structField)
+}
+
+// structFields returns the fields encoding/json would encode for a struct of
+// type t, applying the same rules for promoting the fields of embedded structs.
+func structFields(t reflect.Type) []structField {
+	type queued struct {
+		t     reflect.Type
+		index []int
+	}
+
+	var fields []structField
+	taken := map[string]bool{}
+	visited := map[reflect.Type]bool{}
+	next := []queued{{t: t}}
+
+	// Walk the embedded structs breadth-first, so that shallower fields come
+	// before deeper ones.
+	for len(next) > 0 {
+		current := next
+		next = nil
+
+		// How many times each name appears at this depth.
+		count := map[string]int{}
+		var found []structField
+
+		for _, q := range current {
+			if visited[q.t] {
+				continue
+			}
+			visited[q.t] = true
+
+			for i := 0; i < q.t.NumField(); i++ {
+				sf := q.t.Field(i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				if sf.Anonymous {
+					if sf.PkgPath != "" && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+
+				name, opts := tag, ""
+				if i := strings.Index(tag, ","); i != -1 {
+					name, opts = tag[:i], tag[i:]
+				}
+
+				index := make([]int, len(q.index)+1)
+				copy(index, q.index)
+				index[len(q.index)] = i
+
+				// Untagged embedded structs have their fields promoted.
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, queued{t: ft, index: index})
+					continue
+				}
+
+				tagged := name != ""
+				if !tagged {
+					name = sf.Name
+				}
+
+				quoted := false
+				if strings.Contains(opts, ",string") {
+					switch ft.Kind() {
+					case reflect.Bool, reflect.String,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+						reflect.Float32, reflect.Float64:
+						quoted = true
+					}
+				}
+
+				count[name]++
+				found = append(found, structField{
+					name:      name,
+					tagged:    tagged,
+					index:     index,
+					omitEmpty: strings.Contains(opts, ",omitempty"),
+					quoted:    quoted,
+				})
+			}
+		}
+
+		// A name defined at a shallower depth hides all deeper ones. Among
+		// fields at the same depth, a tagged field beats untagged ones; any other
+		// conflict means the name is dropped altogether.
+		for _, f := range found {
+			if taken[f.name] {
+				continue
+			}
+
+			if count[f.name] > 1 {
+				var dominant []structField
+				for _, g := range found {
+					if g.name == f.name && g.tagged {
+						dominant = append(dominant, g)
+					}
+				}
+
+				taken[f.name] = true
+				if len(dominant) == 1 {
+					fields = append(fields, dominant[0])
+				}
+
+				continue
+			}
+
+			taken[f.name] = true
+			fields = append(fields, f)
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,308 @@
+package jsonvalidate
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/json-validate/json-pointer-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// deepJSONValue applies jsonValue recursively, so that the result can be
+// compared against the output of json.Unmarshal.
+func deepJSONValue(t *testing.T, v interface{}) interface{} {
+	v, err := jsonValue(v)
+	assert.NoError(t, err)
+
+	// jsonValue passes numbers through as Go numbers, whereas json.Unmarshal
+	// produces float64s.
+	if f, ok := numberValue(v); ok {
+		v = f
+	}
+
+	switch v := v.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, elem := range v {
+			out[i] = deepJSONValue(t, elem)
+		}
+
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			out[k] = deepJSONValue(t, elem)
+		}
+
+		return out
+	}
+
+	return v
+}
+
+type testEmbedded struct {
+	A string
+	B string `json:"b"`
+}
+
+type testPointerEmbedded struct {
+	C int
+}
+
+type testMarshaler struct{}
+
+func (testMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"marshaled":true}`), nil
+}
+
+type testPointerMarshaler struct{}
+
+func (*testPointerMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`"pointer"`), nil
+}
+
+type testStruct struct {
+	testEmbedded
+	*testPointerEmbedded
+
+	A          int               `json:"a"`
+	Omitted    string            `json:"omitted,omitempty"`
+	Ignored    string            `json:"-"`
+	Quoted     int64             `json:"quoted,string"`
+	Pointer    *float32          `json:"pointer"`
+	Nil        *string           `json:"nil"`
+	Bytes      []byte            `json:"bytes"`
+	Ints       []int             `json:"ints"`
+	NilSlice   []string          `json:"nilSlice"`
+	Array      [2]bool           `json:"array"`
+	Map        map[string]string `json:"map"`
+	IntMap     map[int]uint8     `json:"intMap"`
+	Interface  interface{}       `json:"interface"`
+	Marshaler  testMarshaler     `json:"marshaler"`
+	PMarshaler testPointerMarshaler
+	Time       time.Time `json:"time"`
+
+	unexported string
+}
+
+func TestJSONValue(t *testing.T) {
+	f := float32(1.5)
+	testCases := []interface{}{
+		nil,
+		true,
+		1.0,
+		"a",
+		[]interface{}{1.0, "a"},
+		map[string]interface{}{"a": []interface{}{}},
+		int8(-3),
+		uint64(3),
+		&f,
+		[]string{"a", "b"},
+		map[string][]int{"a": []int{1, 2}},
+		testStruct{},
+		&testStruct{
+			testEmbedded:        testEmbedded{A: "shadowed", B: "b"},
+			testPointerEmbedded: &testPointerEmbedded{C: 3},
+			A:                   1,
+			Omitted:             "present",
+			Ignored:             "ignored",
+			Quoted:              42,
+			Pointer:             &f,
+			Bytes:               []byte("bytes"),
+			Ints:                []int{1, 2, 3},
+			Array:               [2]bool{true, false},
+			Map:                 map[string]string{"a": "b"},
+			IntMap:              map[int]uint8{1: 2},
+			Interface:           []testEmbedded{{A: "a"}},
+			Time:                time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+			unexported:          "unexported",
+		},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			data, err := json.Marshal(tt)
+			assert.NoError(t, err)
+
+			var expected interface{}
+			assert.NoError(t, json.Unmarshal(data, &expected))
+			assert.Equal(t, expected, deepJSONValue(t, tt))
+		})
+	}
+}
+
+type testID uint64
+
+func TestJSONValueIntegers(t *testing.T) {
+	// Integers too large for a float64 keep their exact value.
+	value, err := jsonValue(struct {
+		ID  testID
+		IDs []int64
+	}{testID(1<<53 + 1), []int64{-1<<53 - 1}})
+	assert.NoError(t, err)
+
+	id, err := jsonValue(value.(map[string]interface{})["ID"])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1<<53+1), id)
+
+	ids, err := jsonValue(value.(map[string]interface{})["IDs"])
+	assert.NoError(t, err)
+
+	elem, err := jsonValue(ids.([]interface{})[0])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1<<53-1), elem)
+}
+
+func TestValidateStruct(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"a": {"type": "number"},
+			"b": {"type": "string"},
+			"ints": {"elements": {"type": "number"}},
+			"map": {"values": {"type": "number"}}
+		}
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	instance := testStruct{
+		testEmbedded: testEmbedded{B: "b"},
+		A:            1,
+		Ints:         []int{1, 2},
+		Map:          map[string]string{"x": "y"},
+	}
+
+	expected := []ValidationError{
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{"map", "x"}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"properties", "map", "values", "type"}},
+		},
+	}
+
+	result, err := validator.Validate(instance)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result.Errors)
+
+	result, err = program.Validate(&instance)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result.Errors)
+}
+
+type testNumbers struct {
+	IDs []json.Number `json:"ids"`
+	ID  json.Number   `json:"id"`
+}
+
+func TestValidateStructNumbers(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {
+			"ids": {"elements": {"type": "uint32"}},
+			"id": {"type": "uint32"}
+		}
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	instance := testNumbers{IDs: []json.Number{"1", "2"}, ID: "3"}
+	for i, tt := range []interface{}{instance, &instance} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			result, err := validator.Validate(tt)
+			assert.NoError(t, err)
+			assert.True(t, result.IsValid())
+
+			result, err = program.Validate(tt)
+			assert.NoError(t, err)
+			assert.True(t, result.IsValid())
+		})
+	}
+}
+
+type testEventType string
+
+type testEvent struct {
+	Type testEventType `json:"type"`
+	Name string        `json:"name"`
+}
+
+type testStringEvent struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+func TestValidateStructDiscriminator(t *testing.T) {
+	var schema SchemaStruct
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"discriminator": {
+			"propertyName": "type",
+			"mapping": {
+				"a": {"properties": {"name": {"type": "string"}}}
+			}
+		}
+	}`), &schema))
+
+	registry, err := NewRegistry([]SchemaStruct{schema})
+	assert.NoError(t, err)
+
+	validator := Validator{Registry: registry}
+	program, err := registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	testCases := []interface{}{
+		testEvent{Type: "a"},
+		&testEvent{Type: "a"},
+		testStringEvent{Type: "a"},
+		&testStringEvent{Type: "a"},
+	}
+
+	for i, tt := range testCases {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			result, err := validator.Validate(tt)
+			assert.NoError(t, err)
+			assert.True(t, result.IsValid())
+
+			result, err = program.Validate(tt)
+			assert.NoError(t, err)
+			assert.True(t, result.IsValid())
+		})
+	}
+
+	// Elements of slices of structs are addressable, so they are reflected as
+	// pointers.
+	elements := SchemaStruct{Elements: &schema}
+	registry, err = NewRegistry([]SchemaStruct{elements})
+	assert.NoError(t, err)
+
+	validator = Validator{Registry: registry}
+	program, err = registry.Compile(url.URL{})
+	assert.NoError(t, err)
+
+	for i, tt := range []interface{}{
+		[]testEvent{{Type: "a"}, {Type: "a", Name: "x"}},
+		[]testStringEvent{{Type: "a"}, {Type: "a", Name: "x"}},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			result, err := validator.Validate(tt)
+			assert.NoError(t, err)
+			assert.True(t, result.IsValid())
+
+			result, err = program.Validate(tt)
+			assert.NoError(t, err)
+			assert.True(t, result.IsValid())
+		})
+	}
+}
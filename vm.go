@@ -1,19 +1,27 @@
 package jsonvalidate
 
 import (
+	"encoding/json"
+	"math"
 	"net/url"
+	"sort"
 	"strconv"
 
 	"github.com/json-validate/json-pointer-go"
 )
 
 type vm struct {
-	maxErrors      int
-	maxDepth       int
-	registry       Registry
-	instanceTokens []string
-	schemas        []schemaStack
-	errors         []ValidationError
+	maxErrors          int
+	maxDepth           int
+	maxInstanceDepth   int
+	strictNumbers      bool
+	disallowAdditional bool
+	registry           Registry
+	instanceTokens     []string
+	schemas            []schemaStack
+	errors             []ValidationError
+	discriminatorTag   *string         // set while evaluating a discriminator mapping
+	done               <-chan struct{} // closed when validation should stop
 }
 
 type schemaStack struct {
@@ -21,7 +29,35 @@ type schemaStack struct {
 	tokens []string
 }
 
+// checkDone returns errDone if validation should stop.
+func (vm *vm) checkDone() error {
+	select {
+	case <-vm.done:
+		return errDone
+	default:
+		return nil
+	}
+}
+
 func (vm *vm) eval(schema *Schema, instance interface{}) error {
+	// The discriminator tag is only exempt from "additionalProperties" in the
+	// schema the discriminator maps to, not in any of its sub-schemas.
+	tag := vm.discriminatorTag
+	vm.discriminatorTag = nil
+
+	if err := vm.checkDone(); err != nil {
+		return err
+	}
+
+	instance, err := jsonValue(instance)
+	if err != nil {
+		return err
+	}
+
+	if schema.Nullable && instance == nil {
+		return nil
+	}
+
 	if schema.RefSchema != nil {
 		tokens := []string{}
 		if schema.Ref.Fragment != "" {
@@ -43,44 +79,29 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 	case SchemaKindEmpty:
 		return nil
 	case SchemaKindType:
-		switch schema.Type {
-		case SchemaTypeNull:
-			if instance != nil {
-				vm.pushSchemaToken("type")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
-		case SchemaTypeBoolean:
-			if _, ok := instance.(bool); !ok {
-				vm.pushSchemaToken("type")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
-		case SchemaTypeNumber:
-			if _, ok := instance.(float64); !ok {
-				vm.pushSchemaToken("type")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
+		if !typeMatches(schema.Type, instance, vm.strictNumbers) {
+			vm.pushSchemaToken("type")
+			if err := vm.reportError(schema, "type"); err != nil {
+				return err
 			}
-		case SchemaTypeString:
-			if _, ok := instance.(string); !ok {
-				vm.pushSchemaToken("type")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
+			vm.popSchemaToken()
+		}
+	case SchemaKindEnum:
+		if !enumContains(schema.Enum, instance) {
+			vm.pushSchemaToken("enum")
+			if err := vm.reportError(schema, "enum"); err != nil {
+				return err
 			}
+			vm.popSchemaToken()
 		}
 	case SchemaKindElements:
 		vm.pushSchemaToken("elements")
 
 		if elems, ok := instance.([]interface{}); ok {
+			if err := vm.checkInstanceDepth(); err != nil {
+				return err
+			}
+
 			for i, elem := range elems {
 				vm.pushInstanceToken(strconv.Itoa(i))
 				if err := vm.eval(schema.Elements, elem); err != nil {
@@ -89,7 +110,7 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 				vm.popInstanceToken()
 			}
 		} else {
-			if err := vm.reportError(); err != nil {
+			if err := vm.reportError(schema, "elements"); err != nil {
 				return err
 			}
 		}
@@ -97,9 +118,14 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 		vm.popSchemaToken()
 	case SchemaKindProperties:
 		if object, ok := instance.(map[string]interface{}); ok {
+			if err := vm.checkInstanceDepth(); err != nil {
+				return err
+			}
+
 			// First, required properties.
 			vm.pushSchemaToken("properties")
-			for property, subSchema := range schema.Properties {
+			for _, property := range sortedSchemaKeys(schema.Properties) {
+				subSchema := schema.Properties[property]
 				vm.pushSchemaToken(property)
 
 				if value, ok := object[property]; ok {
@@ -109,7 +135,7 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 					}
 					vm.popInstanceToken()
 				} else {
-					if err := vm.reportError(); err != nil {
+					if err := vm.reportError(schema, "properties"); err != nil {
 						return err
 					}
 				}
@@ -120,7 +146,8 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 
 			// Then, optional properties.
 			vm.pushSchemaToken("optionalProperties")
-			for property, subSchema := range schema.OptionalProperties {
+			for _, property := range sortedSchemaKeys(schema.OptionalProperties) {
+				subSchema := schema.OptionalProperties[property]
 				vm.pushSchemaToken(property)
 
 				if value, ok := object[property]; ok {
@@ -135,13 +162,29 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 			}
 			vm.popSchemaToken()
 
+			// Finally, properties which the schema doesn't mention at all.
+			if !allowsAdditionalProperties(schema, vm.disallowAdditional) {
+				vm.pushSchemaToken("additionalProperties")
+				for _, property := range sortedObjectKeys(object) {
+					if isDeclaredProperty(schema, property) || (tag != nil && property == *tag) {
+						continue
+					}
+
+					vm.pushInstanceToken(property)
+					if err := vm.reportError(schema, "additionalProperties"); err != nil {
+						return err
+					}
+					vm.popInstanceToken()
+				}
+				vm.popSchemaToken()
+			}
 		} else {
 			// Which errors we're gonna produce has to do with which keywords appeared
 			// in the schema.
 
 			if schema.Properties != nil {
 				vm.pushSchemaToken("properties")
-				if err := vm.reportError(); err != nil {
+				if err := vm.reportError(schema, "properties"); err != nil {
 					return err
 				}
 				vm.popSchemaToken()
@@ -149,7 +192,7 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 
 			if schema.OptionalProperties != nil {
 				vm.pushSchemaToken("optionalProperties")
-				if err := vm.reportError(); err != nil {
+				if err := vm.reportError(schema, "optionalProperties"); err != nil {
 					return err
 				}
 				vm.popSchemaToken()
@@ -159,15 +202,19 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 		vm.pushSchemaToken("values")
 
 		if object, ok := instance.(map[string]interface{}); ok {
-			for key, value := range object {
+			if err := vm.checkInstanceDepth(); err != nil {
+				return err
+			}
+
+			for _, key := range sortedObjectKeys(object) {
 				vm.pushInstanceToken(key)
-				if err := vm.eval(schema.Values, value); err != nil {
+				if err := vm.eval(schema.Values, object[key]); err != nil {
 					return err
 				}
 				vm.popInstanceToken()
 			}
 		} else {
-			if err := vm.reportError(); err != nil {
+			if err := vm.reportError(schema, "values"); err != nil {
 				return err
 			}
 		}
@@ -178,10 +225,16 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 
 		if object, ok := instance.(map[string]interface{}); ok {
 			if prop, ok := object[schema.DiscriminatorPropertyName]; ok {
+				prop, err := jsonValue(prop)
+				if err != nil {
+					return err
+				}
+
 				if propStr, ok := prop.(string); ok {
 					if subSchema, ok := schema.DiscriminatorMapping[propStr]; ok {
 						vm.pushSchemaToken("mapping")
 						vm.pushSchemaToken(propStr)
+						vm.discriminatorTag = &schema.DiscriminatorPropertyName
 						if err := vm.eval(subSchema, instance); err != nil {
 							return err
 						}
@@ -190,7 +243,7 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 					} else {
 						vm.pushSchemaToken("mapping")
 						vm.pushInstanceToken(schema.DiscriminatorPropertyName)
-						if err := vm.reportError(); err != nil {
+						if err := vm.reportError(schema, "discriminator"); err != nil {
 							return err
 						}
 						vm.popInstanceToken()
@@ -199,7 +252,7 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 				} else {
 					vm.pushSchemaToken("propertyName")
 					vm.pushInstanceToken(schema.DiscriminatorPropertyName)
-					if err := vm.reportError(); err != nil {
+					if err := vm.reportError(schema, "discriminator"); err != nil {
 						return err
 					}
 					vm.popInstanceToken()
@@ -207,14 +260,14 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 				}
 			} else {
 				vm.pushSchemaToken("propertyName")
-				if err := vm.reportError(); err != nil {
+				if err := vm.reportError(schema, "discriminator"); err != nil {
 					return err
 				}
 				vm.popSchemaToken()
 			}
 
 		} else {
-			if err := vm.reportError(); err != nil {
+			if err := vm.reportError(schema, "discriminator"); err != nil {
 				return err
 			}
 		}
@@ -225,7 +278,186 @@ func (vm *vm) eval(schema *Schema, instance interface{}) error {
 	return nil
 }
 
-func (vm *vm) reportError() error {
+// typeMatches returns whether instance satisfies a "type" keyword with value t.
+func typeMatches(t SchemaType, instance interface{}, strictNumbers bool) bool {
+	switch t {
+	case SchemaTypeNull:
+		return instance == nil
+	case SchemaTypeBoolean:
+		_, ok := instance.(bool)
+		return ok
+	case SchemaTypeNumber:
+		return isNumber(instance, strictNumbers)
+	case SchemaTypeString:
+		_, ok := instance.(string)
+		return ok
+	case SchemaTypeInt8:
+		return isInteger(instance, math.MinInt8, math.MaxInt8)
+	case SchemaTypeUint8:
+		return isInteger(instance, 0, math.MaxUint8)
+	case SchemaTypeInt16:
+		return isInteger(instance, math.MinInt16, math.MaxInt16)
+	case SchemaTypeUint16:
+		return isInteger(instance, 0, math.MaxUint16)
+	case SchemaTypeInt32:
+		return isInteger(instance, math.MinInt32, math.MaxInt32)
+	case SchemaTypeUint32:
+		return isInteger(instance, 0, math.MaxUint32)
+	case SchemaTypeFloat32:
+		f, ok := numberValue(instance)
+		return ok && math.Abs(f) <= math.MaxFloat32
+	case SchemaTypeFloat64:
+		_, ok := numberValue(instance)
+		return ok
+	case SchemaTypeTimestamp:
+		s, ok := instance.(string)
+		return ok && isTimestamp(s)
+	}
+
+	return false
+}
+
+// allowsAdditionalProperties returns whether a schema with the "properties" or
+// "optionalProperties" keywords accepts properties it does not declare. If the
+// schema doesn't say, disallowByDefault decides.
+func allowsAdditionalProperties(schema *Schema, disallowByDefault bool) bool {
+	if schema.AdditionalProperties != nil {
+		return *schema.AdditionalProperties
+	}
+
+	return !disallowByDefault
+}
+
+// isDeclaredProperty returns whether a schema declares a property in either
+// "properties" or "optionalProperties".
+func isDeclaredProperty(schema *Schema, property string) bool {
+	if _, ok := schema.Properties[property]; ok {
+		return true
+	}
+
+	_, ok := schema.OptionalProperties[property]
+	return ok
+}
+
+// enumContains returns whether instance is a member of an "enum" keyword.
+func enumContains(enum map[string]struct{}, instance interface{}) bool {
+	s, ok := instance.(string)
+	if !ok {
+		return false
+	}
+
+	_, ok = enum[s]
+	return ok
+}
+
+// isNumber returns whether instance is a number. Every Go numeric type is
+// accepted, as is json.Number.
+//
+// If strict is true, only numbers which JSON can represent are accepted. In
+// particular, a json.Number must then be a syntactically valid JSON number.
+func isNumber(instance interface{}, strict bool) bool {
+	if strict {
+		_, ok := numberValue(instance)
+		return ok
+	}
+
+	switch instance.(type) {
+	case float64, float32, json.Number:
+		return true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		return true
+	}
+
+	return false
+}
+
+// isInteger returns whether instance is a number with no fractional part
+// within the range [min, max].
+func isInteger(instance interface{}, min, max float64) bool {
+	f, ok := numberValue(instance)
+	return ok && f == math.Trunc(f) && f >= min && f <= max
+}
+
+// numberValue converts a number to a float64. It returns false if instance is
+// not a number, or is not a number JSON can represent.
+func numberValue(instance interface{}) (float64, bool) {
+	var f float64
+	switch n := instance.(type) {
+	case float64:
+		f = n
+	case float32:
+		f = float64(n)
+	case int:
+		f = float64(n)
+	case int8:
+		f = float64(n)
+	case int16:
+		f = float64(n)
+	case int32:
+		f = float64(n)
+	case int64:
+		f = float64(n)
+	case uint:
+		f = float64(n)
+	case uint8:
+		f = float64(n)
+	case uint16:
+		f = float64(n)
+	case uint32:
+		f = float64(n)
+	case uint64:
+		f = float64(n)
+	case uintptr:
+		f = float64(n)
+	case json.Number:
+		// strconv.ParseFloat accepts things like "Inf" and "0x1p-2", which JSON
+		// does not.
+		if n == "" || (n[0] != '-' && (n[0] < '0' || n[0] > '9')) || !json.Valid([]byte(n)) {
+			return 0, false
+		}
+
+		var err error
+		if f, err = strconv.ParseFloat(string(n), 64); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	return f, isFinite(f)
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// sortedSchemaKeys returns the keys of schemas, in sorted order. Evaluating
+// properties in this order makes the order of errors deterministic.
+func sortedSchemaKeys(schemas map[string]*Schema) []string {
+	keys := make([]string, 0, len(schemas))
+	for k := range schemas {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedObjectKeys returns the keys of object, in sorted order.
+func sortedObjectKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for k := range object {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// reportError records an error at the current schema and instance paths.
+// schema and keyword are the schema node reporting the error, and the keyword
+// of it which isn't satisfied, from which the error gets its CustomMessage.
+func (vm *vm) reportError(schema *Schema, keyword string) error {
 	schemaStack := vm.schemas[len(vm.schemas)-1]
 	instancePath := make([]string, len(vm.instanceTokens))
 	schemaPath := make([]string, len(schemaStack.tokens))
@@ -234,9 +466,10 @@ func (vm *vm) reportError() error {
 	copy(schemaPath, schemaStack.tokens)
 
 	vm.errors = append(vm.errors, ValidationError{
-		InstancePath: jsonpointer.Ptr{Tokens: instancePath},
-		SchemaPath:   jsonpointer.Ptr{Tokens: schemaPath},
-		SchemaURI:    *schemaStack.uri,
+		InstancePath:  jsonpointer.Ptr{Tokens: instancePath},
+		SchemaPath:    jsonpointer.Ptr{Tokens: schemaPath},
+		SchemaURI:     *schemaStack.uri,
+		CustomMessage: customMessage(schema, keyword),
 	})
 
 	if len(vm.errors) == vm.maxErrors {
@@ -246,6 +479,16 @@ func (vm *vm) reportError() error {
 	return nil
 }
 
+// checkInstanceDepth is called before evaluating the contents of an array or
+// object, and ensures the array or object is not nested too deeply.
+func (vm *vm) checkInstanceDepth() error {
+	if len(vm.instanceTokens) == vm.maxInstanceDepth {
+		return ErrMaxInstanceDepth
+	}
+
+	return nil
+}
+
 func (vm *vm) pushInstanceToken(t string) {
 	vm.instanceTokens = append(vm.instanceTokens, t)
 }